@@ -0,0 +1,28 @@
+package xwis
+
+const apgarLookup = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789./"
+
+// Apgar encodes the password the same way as Westwood clients do before sending it with the apgar command.
+//
+// The encoded password is always 8 characters long. Game clients limit passwords to 8 characters.
+func Apgar(pass string) string {
+	at := func(i int) byte {
+		if i < 0 || i >= len(pass) {
+			return 0
+		}
+		return pass[i]
+	}
+	var out [8]byte
+	for i := range out {
+		left := at(i)
+		right := at(len(pass) - i)
+		var v byte
+		if left&1 != 0 {
+			v = ((left << 1) ^ (left & 1)) & right
+		} else {
+			v = left ^ right
+		}
+		out[i] = apgarLookup[v&0x3f]
+	}
+	return string(out[:])
+}
@@ -0,0 +1,35 @@
+package xwis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApgar(t *testing.T) {
+	// vectors are computed with the reference implementation of the algorithm
+	for _, c := range []struct {
+		pass string
+		exp  string
+	}{
+		{pass: "", exp: "aaaaaaaa"},
+		{pass: "a", exp: "aHaaaaaa"},
+		{pass: "testserv", exp: "0cIrJabt"},
+		{pass: "password", exp: "WaINNtbf"},
+		{pass: "secret", exp: "aafadrZa"},
+		{pass: "probe1234", exp: "WgtqbHqN"},
+	} {
+		t.Run(c.pass, func(t *testing.T) {
+			require.Equal(t, c.exp, Apgar(c.pass))
+		})
+	}
+	for _, pass := range []string{"a", "testserv", "password", "probe1234", "\xff\x00\x80"} {
+		enc := Apgar(pass)
+		require.Len(t, enc, 8)
+		for _, r := range enc {
+			require.True(t, strings.ContainsRune(apgarLookup, r))
+		}
+		require.Equal(t, enc, Apgar(pass))
+	}
+}
@@ -0,0 +1,274 @@
+package xwis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/irc.v3"
+)
+
+var (
+	ErrSlowConsumer       = errors.New("subscription consumer is too slow")
+	ErrSubscriptionClosed = errors.New("subscription closed")
+)
+
+const (
+	defaultSubBuffer    = 16
+	slowConsumerTimeout = 5 * time.Second
+)
+
+// Filter selects messages delivered to a Subscription.
+type Filter func(m *irc.Message) bool
+
+// MatchCommands selects messages with one of the given commands (or numeric replies).
+func MatchCommands(cmds ...string) Filter {
+	return func(m *irc.Message) bool {
+		for _, c := range cmds {
+			if c == m.Command {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchChannel selects messages that mention the given channel in any of the parameters.
+func MatchChannel(name string) Filter {
+	return func(m *irc.Message) bool {
+		for _, p := range m.Params {
+			if strings.EqualFold(p, name) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchAll selects messages that match all given filters.
+func MatchAll(filters ...Filter) Filter {
+	return func(m *irc.Message) bool {
+		for _, f := range filters {
+			if !f(m) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAny selects messages that match any of the given filters.
+func MatchAny(filters ...Filter) Filter {
+	return func(m *irc.Message) bool {
+		for _, f := range filters {
+			if f(m) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Subscription is a buffered feed of messages received by the Client.
+//
+// If the consumer does not read messages for too long and the buffer is full, the subscription
+// is terminated with ErrSlowConsumer. This way a single consumer cannot stall the Client.
+type Subscription struct {
+	c      *Client
+	filter Filter
+	msg    chan *irc.Message
+	done   chan struct{}
+	keep   bool // survives reconnects
+	once   sync.Once
+	err    error
+}
+
+// Subscribe creates a new subscription for messages matching the filter.
+// Nil filter selects all messages. If size is zero, the default buffer size is used.
+// Subscription must be closed after use.
+//
+// In the reconnecting mode, subscriptions survive reconnects.
+func (c *Client) Subscribe(filter Filter, size int) *Subscription {
+	return c.subscribe(filter, size, true)
+}
+
+// request creates a subscription for replies to a single request.
+// Unlike Subscribe, it is terminated when the connection is lost.
+func (c *Client) request(filter Filter, size int) *Subscription {
+	return c.subscribe(filter, size, false)
+}
+
+func (c *Client) subscribe(filter Filter, size int, keep bool) *Subscription {
+	if size <= 0 {
+		size = defaultSubBuffer
+	}
+	s := &Subscription{
+		c:      c,
+		filter: filter,
+		msg:    make(chan *irc.Message, size),
+		done:   make(chan struct{}),
+		keep:   keep,
+	}
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	if c.rerr != nil {
+		s.stop(c.rerr)
+		return s
+	}
+	c.subs[s] = struct{}{}
+	return s
+}
+
+func (c *Client) unsubscribe(s *Subscription) {
+	c.smu.Lock()
+	delete(c.subs, s)
+	c.smu.Unlock()
+}
+
+// stopSubs terminates all subscriptions with a given error.
+func (c *Client) stopSubs(err error) {
+	c.smu.Lock()
+	c.rerr = err
+	subs := c.subs
+	c.subs = make(map[*Subscription]struct{})
+	c.smu.Unlock()
+	for s := range subs {
+		s.stop(err)
+	}
+}
+
+// stopRequests terminates all request subscriptions with a given error.
+func (c *Client) stopRequests(err error) {
+	var reqs []*Subscription
+	c.smu.Lock()
+	for s := range c.subs {
+		if !s.keep {
+			reqs = append(reqs, s)
+			delete(c.subs, s)
+		}
+	}
+	c.smu.Unlock()
+	for _, s := range reqs {
+		s.stop(err)
+	}
+}
+
+// dispatch delivers the message to all matching subscriptions.
+func (c *Client) dispatch(m *irc.Message) {
+	c.smu.Lock()
+	subs := make([]*Subscription, 0, len(c.subs))
+	for s := range c.subs {
+		if s.filter == nil || s.filter(m) {
+			subs = append(subs, s)
+		}
+	}
+	c.smu.Unlock()
+	for _, s := range subs {
+		s.deliver(m)
+	}
+}
+
+func (s *Subscription) deliver(m *irc.Message) {
+	select {
+	case s.msg <- m:
+		return
+	case <-s.done:
+		return
+	default:
+	}
+	t := time.NewTimer(slowConsumerTimeout)
+	defer t.Stop()
+	select {
+	case s.msg <- m:
+	case <-s.done:
+	case <-s.c.stop:
+	case <-t.C:
+		if log := s.c.log(); log != nil {
+			log.Printf("subscription dropped: %v", ErrSlowConsumer)
+		}
+		s.c.unsubscribe(s)
+		s.stop(ErrSlowConsumer)
+	}
+}
+
+func (s *Subscription) stop(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+// Messages returns a channel with messages for this subscription.
+// The channel is never closed; use Done to detect when the subscription ends.
+func (s *Subscription) Messages() <-chan *irc.Message {
+	return s.msg
+}
+
+// Done returns a channel that is closed when the subscription ends.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the reason why subscription ended. It returns nil if subscription is still active.
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Next waits for the next message in the subscription.
+//
+// Messages buffered before the connection was lost are still returned, but a subscription closed
+// by the consumer returns ErrSubscriptionClosed immediately.
+func (s *Subscription) Next(ctx context.Context) (*irc.Message, error) {
+	if err := s.Err(); err == ErrSubscriptionClosed {
+		return nil, err
+	}
+	select {
+	case m := <-s.msg:
+		return m, nil
+	default:
+	}
+	select {
+	case m := <-s.msg:
+		return m, nil
+	case <-s.c.stop:
+		return nil, ErrClientClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.done:
+		return nil, s.err
+	}
+}
+
+// WaitFor waits for the message with one of the given commands. Other messages are skipped.
+// If a numeric error reply is received instead, it is returned as *ServerError.
+func (s *Subscription) WaitFor(ctx context.Context, cmds ...string) (*irc.Message, error) {
+	for {
+		m, err := s.Next(ctx)
+		if err != nil {
+			return nil, fmt.Errorf(pkg+": wait(%s): %w", strings.Join(cmds, "|"), err)
+		}
+		for _, c := range cmds {
+			if c == m.Command {
+				return m, nil
+			}
+		}
+		if isErrorReply(m) {
+			return nil, newServerError(m)
+		}
+	}
+}
+
+// Close the subscription.
+func (s *Subscription) Close() error {
+	s.c.unsubscribe(s)
+	s.stop(ErrSubscriptionClosed)
+	return nil
+}
@@ -0,0 +1,150 @@
+package xwis
+
+import (
+	"context"
+	"strings"
+)
+
+const chatBufferSize = 64
+
+// ChatMessage is a chat message received from XWIS.
+type ChatMessage struct {
+	// From is the nick of the sender.
+	From string
+	// Channel is the channel the message was sent to, including '#' prefix.
+	// For private messages it is set to the nick of the current user.
+	Channel string
+	// Text of the message.
+	Text string
+}
+
+// Private checks if the message was sent directly to the user instead of a channel.
+func (m ChatMessage) Private() bool {
+	return !strings.HasPrefix(m.Channel, "#")
+}
+
+// Channel is a chat channel joined by the client.
+type Channel struct {
+	c      *Client
+	name   string
+	closed bool
+}
+
+// Name of the channel, including '#' prefix.
+func (ch *Channel) Name() string {
+	return ch.name
+}
+
+// Send a message to the channel.
+func (ch *Channel) Send(ctx context.Context, text string) error {
+	return ch.c.writeChatReq(ctx, ch.name, text)
+}
+
+// Close leaves the channel.
+func (ch *Channel) Close() error {
+	if ch.closed {
+		return nil
+	}
+	ch.closed = true
+	ch.c.mu.Lock()
+	delete(ch.c.channels, ch.name)
+	ch.c.mu.Unlock()
+	return ch.c.writePartReq(context.Background(), ch.name)
+}
+
+// Messages returns a stream of chat messages received by the client on all joined channels,
+// including private messages. Messages are dropped if the consumer cannot keep up.
+func (c *Client) Messages() <-chan ChatMessage {
+	return c.chat
+}
+
+// JoinChannel joins a chat channel, for example "#Lob_37_0".
+func (c *Client) JoinChannel(ctx context.Context, name string) (*Channel, error) {
+	if !strings.HasPrefix(name, "#") {
+		name = "#" + name
+	}
+	read, err := c.writeJoinReq(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	_, err = read.WaitFor(ctx, "366")
+	_ = read.Close()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.channels[name] = struct{}{}
+	c.mu.Unlock()
+	return &Channel{c: c, name: name}, nil
+}
+
+func (c *Client) writeJoinReq(ctx context.Context, channel string) (*Subscription, error) {
+	channel, err := c.opts.codePage.EncodeString(channel)
+	if err != nil {
+		return nil, err
+	}
+	read := c.request(MatchAny(MatchAll(MatchCommands("366"), MatchChannel(channel)), matchErrors(channel)), 0)
+	err = c.writeReq(ctx, func(w *writer) error {
+		return w.WriteLinef("JOIN %s", channel)
+	})
+	if err != nil {
+		read.Close()
+		return nil, err
+	}
+	return read, nil
+}
+
+func (c *Client) writeChatReq(ctx context.Context, channel, text string) error {
+	channel, err := c.opts.codePage.EncodeString(channel)
+	if err != nil {
+		return err
+	}
+	text, err = c.opts.codePage.EncodeString(text)
+	if err != nil {
+		return err
+	}
+	return c.writeReq(ctx, func(w *writer) error {
+		return w.WriteLinef("PRIVMSG %s :%s", channel, text)
+	})
+}
+
+func (c *Client) writePartReq(ctx context.Context, channel string) error {
+	channel, err := c.opts.codePage.EncodeString(channel)
+	if err != nil {
+		return err
+	}
+	return c.writeReq(ctx, func(w *writer) error {
+		return w.WriteLinef("PART %s", channel)
+	})
+}
+
+// chatLoop converts chat messages from the subscription and sends them to the consumer.
+// Messages are dropped if the consumer is not reading them, so the subscription is never stalled.
+func (c *Client) chatLoop(sub *Subscription) {
+	defer sub.Close()
+	for {
+		select {
+		case <-sub.Done():
+			return
+		case m := <-sub.Messages():
+			if len(m.Params) < 2 {
+				continue
+			}
+			cp := c.opts.codePage
+			msg := ChatMessage{
+				Channel: cp.DecodeString(m.Params[0]),
+				Text:    cp.DecodeString(m.Params[1]),
+			}
+			if m.Prefix != nil {
+				msg.From = cp.DecodeString(m.Prefix.Name)
+			}
+			select {
+			case c.chat <- msg:
+			default:
+				if log := c.log(); log != nil {
+					log.Printf("chat message dropped: %q", m.String())
+				}
+			}
+		}
+	}
+}
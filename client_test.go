@@ -0,0 +1,835 @@
+package xwis_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noxworld-dev/xwis"
+	"github.com/noxworld-dev/xwis/xwistest"
+)
+
+func newTestClient(t testing.TB, srv *xwistest.Server, login string) *xwis.Client {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	cli, err := xwis.NewClientWithAddress(ctx, srv.Addr(), login, "")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = cli.Close()
+	})
+	return cli
+}
+
+func TestFakeLogin(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+
+	newTestClient(t, srv, "testserv")
+	require.Equal(t, []string{"testserv"}, srv.Users())
+}
+
+func TestFakeRegister(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+	srv.AddChatRoom("#Lob_37_0", 3)
+
+	cli := newTestClient(t, srv, "testserv")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	info := xwis.GameInfo{
+		Access:     xwis.AccessOpen,
+		Resolution: xwis.Res640x480,
+		Players:    4,
+		MaxPlayers: 31,
+		Map:        "headache",
+		Name:       "Test Server",
+		MapType:    xwis.MapTypeChat,
+		FragLimit:  5,
+	}
+	g, err := cli.RegisterGame(ctx, info)
+	require.NoError(t, err)
+
+	assertGame := func() {
+		list, err := cli.ListRooms(ctx)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+
+		games := srv.Games()
+		require.Len(t, games, 1)
+		require.Equal(t, "#testserv's_game", games[0].Channel)
+		require.Equal(t, "testserv", games[0].Host)
+		require.Equal(t, 31, games[0].MaxPlayers)
+		require.NotNil(t, games[0].Info)
+		require.Equal(t, info.Name, games[0].Info.Name)
+		require.Equal(t, info.Map, games[0].Info.Map)
+		require.Equal(t, info.Players, games[0].Info.Players)
+
+		var found bool
+		for _, r := range list {
+			if r.Game == nil {
+				require.Equal(t, "Brin", r.Name)
+				require.Equal(t, 3, r.Users)
+				continue
+			}
+			found = true
+			require.Equal(t, "127.0.0.1", r.Game.Addr)
+			require.Equal(t, info.Name, r.Game.Name)
+			require.Equal(t, info.Map, r.Game.Map)
+			require.Equal(t, info.MapType, r.Game.MapType)
+			require.Equal(t, xwis.RoomGame, r.Kind)
+			require.Equal(t, info.Players, r.Users)
+			require.Equal(t, "127.0.0.1", r.Addr)
+			require.Equal(t, 1, r.ChannelUsers)
+			require.Equal(t, 31, r.MaxUsers)
+			require.Equal(t, xwis.ProductNox.GameID, r.GameType)
+			require.False(t, r.Tournament)
+			require.False(t, r.Full())
+			require.Len(t, r.Params, 8)
+			require.Equal(t, r.ID, r.Params[0])
+			require.Equal(t, &xwis.PayloadHeader{
+				Prefix: "128", Magic: "G1P3", Version: [3]byte{0x9a, 0x03, 0x01},
+			}, r.Header)
+		}
+		require.True(t, found)
+	}
+	assertGame()
+
+	info.Name += " 1"
+	info.Map += "1"
+	info.Players += 2
+	err = g.Update(ctx, info)
+	require.NoError(t, err)
+	assertGame()
+
+	err = g.Close()
+	require.NoError(t, err)
+
+	list, err := cli.ListRooms(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Empty(t, srv.Games())
+}
+
+func TestFakeChatRooms(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+	srv.AddChatRoom("#Lob_37_1", 2)
+	srv.AddChatRoom("#Lob_37_5", 3)
+	srv.AddChatRoom("#Lob_37_x", 4)
+	srv.AddChatRoom("#Lob_18_0", 5)
+	srv.AddChatRoom("#mychat", 6)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	cli := newTestClient(t, srv, "user1")
+	list, err := cli.ListRooms(ctx)
+	require.NoError(t, err)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	require.Equal(t, []xwis.Room{
+		{ID: "#Lob_18_0", Name: "Lob_18_0", Kind: xwis.RoomChat, Users: 5, ChannelFlags: 388, Params: []string{"#Lob_18_0", "5", "0", "388"}},
+		{ID: "#Lob_37_1", Name: "Ix", Kind: xwis.RoomLobby, Users: 2, ChannelFlags: 388, Params: []string{"#Lob_37_1", "2", "0", "388"}},
+		{ID: "#Lob_37_5", Name: "Lobby 6", Kind: xwis.RoomLobby, Users: 3, ChannelFlags: 388, Params: []string{"#Lob_37_5", "3", "0", "388"}},
+		{ID: "#Lob_37_x", Name: "Lob_37_x", Kind: xwis.RoomChat, Users: 4, ChannelFlags: 388, Params: []string{"#Lob_37_x", "4", "0", "388"}},
+		{ID: "#mychat", Name: "mychat", Kind: xwis.RoomChat, Users: 6, ChannelFlags: 388, Params: []string{"#mychat", "6", "0", "388"}},
+	}, list)
+
+	data, err := json.Marshal(list[1])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":"#Lob_37_1","name":"Ix","kind":"lobby","users":2,"channel_flags":388,"params":["#Lob_37_1","2","0","388"]}`, string(data))
+	var r xwis.Room
+	require.NoError(t, json.Unmarshal(data, &r))
+	require.Equal(t, list[1], r)
+}
+
+func TestFakeListLobbyServers(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	list, err := xwis.ListLobbyServersWithAddress(ctx, srv.Addr())
+	require.NoError(t, err)
+	require.Equal(t, []xwis.LobbyServer{
+		{Addr: srv.Addr(), Name: "XWIS"},
+	}, list)
+}
+
+func TestFakeChat(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+	srv.AddChatRoom("#Lob_37_0", 0)
+
+	cli1 := newTestClient(t, srv, "user1")
+	cli2 := newTestClient(t, srv, "user2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	ch1, err := cli1.JoinChannel(ctx, "#Lob_37_0")
+	require.NoError(t, err)
+	defer ch1.Close()
+	require.Equal(t, "#Lob_37_0", ch1.Name())
+
+	ch2, err := cli2.JoinChannel(ctx, "Lob_37_0")
+	require.NoError(t, err)
+	defer ch2.Close()
+
+	err = ch1.Send(ctx, "hello there")
+	require.NoError(t, err)
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("timeout")
+	case m := <-cli2.Messages():
+		require.Equal(t, xwis.ChatMessage{From: "user1", Channel: "#Lob_37_0", Text: "hello there"}, m)
+		require.False(t, m.Private())
+	}
+	require.Equal(t, []xwistest.Message{
+		{From: "user1", To: "#Lob_37_0", Text: "hello there"},
+	}, srv.Messages())
+
+	srv.SendMessage("admin", "user1", "hi")
+	select {
+	case <-ctx.Done():
+		t.Fatal("timeout")
+	case m := <-cli1.Messages():
+		require.Equal(t, xwis.ChatMessage{From: "admin", Channel: "user1", Text: "hi"}, m)
+		require.True(t, m.Private())
+	}
+}
+
+func TestFakeConcurrent(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+	srv.AddChatRoom("#Lob_37_0", 0)
+	srv.AddChatRoom("#Lob_37_1", 5)
+
+	cli := newTestClient(t, srv, "testserv")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	sub := cli.Subscribe(xwis.MatchChannel("#Lob_37_0"), 0)
+	defer sub.Close()
+
+	ch, err := cli.JoinChannel(ctx, "#Lob_37_0")
+	require.NoError(t, err)
+	defer ch.Close()
+
+	errc := make(chan error, 10)
+	for i := 0; i < cap(errc); i++ {
+		go func() {
+			list, err := cli.ListRooms(ctx)
+			if err == nil && len(list) != 2 {
+				err = fmt.Errorf("unexpected list: %v", list)
+			}
+			errc <- err
+		}()
+	}
+	srv.SendMessage("user", "#Lob_37_0", "hi")
+	for i := 0; i < cap(errc); i++ {
+		require.NoError(t, <-errc)
+	}
+
+	m, err := sub.WaitFor(ctx, "PRIVMSG")
+	require.NoError(t, err)
+	require.Equal(t, "user", m.Prefix.Name)
+	require.Equal(t, []string{"#Lob_37_0", "hi"}, m.Params)
+
+	require.NoError(t, sub.Close())
+	_, err = sub.Next(ctx)
+	require.Equal(t, xwis.ErrSubscriptionClosed, err)
+}
+
+func TestFakeReconnect(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+	srv.AddChatRoom("#Lob_37_0", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	events := make(chan xwis.ReconnectEvent, 10)
+	cli, err := xwis.NewReconnectingClient(ctx, srv.Addr(), "testserv", "", xwis.ReconnectConfig{
+		MinDelay: time.Millisecond,
+		OnEvent: func(ev xwis.ReconnectEvent) {
+			events <- ev
+		},
+	})
+	require.NoError(t, err)
+	defer cli.Close()
+
+	ch, err := cli.JoinChannel(ctx, "#Lob_37_0")
+	require.NoError(t, err)
+	defer ch.Close()
+
+	info := xwis.GameInfo{
+		Name:       "Test Server",
+		Map:        "headache",
+		MapType:    xwis.MapTypeArena,
+		MaxPlayers: 31,
+	}
+	g, err := cli.RegisterGame(ctx, info)
+	require.NoError(t, err)
+	defer g.Close()
+
+	info.Players = 3
+	err = g.Update(ctx, info)
+	require.NoError(t, err)
+
+	_, err = cli.ListRooms(ctx)
+	require.NoError(t, err)
+	require.Len(t, srv.Games(), 1)
+
+	srv.Disconnect("testserv")
+
+	ev := <-events
+	require.Equal(t, 0, ev.Attempt)
+	require.Error(t, ev.Err)
+
+	ev = <-events
+	require.Equal(t, xwis.ReconnectEvent{Attempt: 1, Connected: true}, ev)
+	require.NoError(t, cli.Err())
+
+	_, err = cli.ListRooms(ctx)
+	require.NoError(t, err)
+	games := srv.Games()
+	require.Len(t, games, 1)
+	require.Equal(t, "Test Server", games[0].Info.Name)
+	require.Equal(t, 3, games[0].Info.Players)
+	require.Equal(t, []xwistest.ChatRoom{
+		{Channel: "#Lob_37_0", Users: 1},
+	}, srv.ChatRooms())
+}
+
+func TestFakeDisconnect(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+
+	cli := newTestClient(t, srv, "testserv")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cli.HostGame(ctx, xwis.GameInfo{Name: "Test Server"})
+	}()
+	for len(srv.Games()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	srv.Disconnect("")
+	err := <-errc
+	require.Error(t, err)
+	require.Equal(t, err, cli.Err())
+}
+
+func TestFakePing(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+
+	cli := newTestClient(t, srv, "testserv")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	srv.Ping("token")
+	// list request makes sure the reply reached the server
+	_, err := cli.ListRooms(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"token"}, srv.Pongs())
+}
+
+func TestFakeKeepAlive(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+
+	cli := newTestClient(t, srv, "testserv")
+	cli.KeepAlive(10*time.Millisecond, 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, cli.Err())
+
+	sub := cli.Subscribe(xwis.MatchCommands("PRIVMSG"), 0)
+	defer sub.Close()
+
+	srv.IgnorePing(true)
+	select {
+	case <-ctx.Done():
+		t.Fatal("timeout")
+	case <-cli.Done():
+	}
+	require.True(t, errors.Is(cli.Err(), xwis.ErrPingTimeout))
+	_, err := sub.Next(ctx)
+	require.True(t, errors.Is(err, xwis.ErrPingTimeout))
+}
+
+func TestFakeErrors(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+	// encoded with the reference implementation, not with xwis.Apgar
+	srv.SetApgarPassword("user1", "aafadrZa")
+	srv.Ban("user2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	_, err := xwis.NewClientWithAddress(ctx, srv.Addr(), "user1", "wrong")
+	require.True(t, errors.Is(err, xwis.ErrBadPassword), "%v", err)
+
+	ok, err := xwis.NewClientWithAddress(ctx, srv.Addr(), "user1", "secret")
+	require.NoError(t, err)
+	ok.Close()
+
+	_, err = xwis.NewClientWithAddress(ctx, srv.Addr(), "user2", "")
+	require.True(t, errors.Is(err, xwis.ErrBanned), "%v", err)
+
+	cli := newTestClient(t, srv, "testserv")
+	_, err = xwis.NewClientWithAddress(ctx, srv.Addr(), "testserv", "")
+	require.True(t, errors.Is(err, xwis.ErrNickInUse), "%v", err)
+
+	srv.FailCommand("JOINGAME", "437")
+	_, err = cli.RegisterGame(ctx, xwis.GameInfo{Name: "Test"})
+	require.True(t, errors.Is(err, xwis.ErrChannelExists), "%v", err)
+
+	srv.FailCommand("LIST", "499")
+	_, err = cli.ListRooms(ctx)
+	var serr *xwis.ServerError
+	require.True(t, errors.As(err, &serr), "%v", err)
+	require.Equal(t, "499", serr.Code)
+	require.Equal(t, []string{"testserv", "Command failed"}, serr.Params)
+	require.EqualError(t, err, "xwis: server error 499: Command failed")
+
+	_, err = cli.ListRooms(ctx)
+	require.NoError(t, err)
+}
+
+func TestFakeProduct(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+	srv.AddChatRoom("#Lob_18_0", 2)
+
+	prod := &xwis.Product{
+		Name:          "Tiberian Sun",
+		GameID:        18,
+		SKU:           18 << 8,
+		ClientVersion: 1,
+		Version:       1,
+		APIVersion:    1,
+		Serial:        "0",
+		Lobbies:       []string{"Lobby"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	cli, err := xwis.NewProductClient(ctx, prod, srv.Addr(), "user1", "")
+	require.NoError(t, err)
+	defer cli.Close()
+	require.Equal(t, prod, cli.Product())
+
+	nox := newTestClient(t, srv, "user2")
+	_, err = nox.RegisterGame(ctx, xwis.GameInfo{Name: "Nox game"})
+	require.NoError(t, err)
+
+	list, err := cli.ListRooms(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []xwis.Room{
+		{ID: "#Lob_18_0", Name: "Lobby", Kind: xwis.RoomLobby, Users: 2, ChannelFlags: 388, Params: []string{"#Lob_18_0", "2", "0", "388"}},
+	}, list)
+
+	list, err = cli.ListProductRooms(ctx, xwis.ProductNox)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	servers, err := xwis.ListProductLobbyServers(ctx, prod, srv.Addr())
+	require.NoError(t, err)
+	require.Len(t, servers, 1)
+}
+
+func TestFakeOptions(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var (
+		buf   bytes.Buffer
+		dials int
+	)
+	cli, err := xwis.NewClientWithAddress(ctx, srv.Addr(), "", "",
+		xwis.WithLogger(log.New(&buf, "", 0)),
+		xwis.WithDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials++
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		}),
+		xwis.WithLoginGenerator(func() string {
+			return "gen"
+		}),
+		xwis.WithTimeout(time.Second),
+		xwis.WithHandshakeTimeout(time.Second),
+	)
+	require.NoError(t, err)
+	defer cli.Close()
+	require.Equal(t, 1, dials)
+	require.Equal(t, []string{"gen"}, srv.Users())
+	require.Contains(t, buf.String(), "NICK gen\n")
+}
+
+func TestFakeWatchRooms(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+	srv.AddChatRoom("#Lob_37_0", 1)
+
+	cli := newTestClient(t, srv, "watcher")
+	host := newTestClient(t, srv, "host")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	_, err := cli.WatchRooms(ctx, 0)
+	require.Error(t, err)
+
+	w, err := cli.WatchRooms(ctx, 10*time.Millisecond)
+	require.NoError(t, err)
+	next := func() xwis.RoomEvent {
+		select {
+		case <-ctx.Done():
+			t.Fatal("timeout")
+		case ev, ok := <-w.Events():
+			require.True(t, ok, "%v", w.Err())
+			return ev
+		}
+		panic("unreachable")
+	}
+
+	ev := next()
+	require.Equal(t, xwis.RoomAdded, ev.Type)
+	require.Equal(t, &xwis.Room{ID: "#Lob_37_0", Name: "Brin", Kind: xwis.RoomLobby, Users: 1, ChannelFlags: 388, Params: []string{"#Lob_37_0", "1", "0", "388"}}, ev.New)
+
+	srv.AddChatRoom("#Lob_37_0", 2)
+	ev = next()
+	require.Equal(t, xwis.RoomChanged, ev.Type)
+	require.Equal(t, 1, ev.Old.Users)
+	require.Equal(t, 2, ev.New.Users)
+
+	info := xwis.GameInfo{Name: "Test", Map: "estate", MaxPlayers: 31}
+	g, err := host.RegisterGame(ctx, info)
+	require.NoError(t, err)
+	ev = next()
+	require.Equal(t, xwis.RoomAdded, ev.Type)
+	require.Equal(t, "Test", ev.New.Game.Name)
+
+	info.Players = 5
+	require.NoError(t, g.Update(ctx, info))
+	ev = next()
+	require.Equal(t, xwis.RoomChanged, ev.Type)
+	require.Equal(t, 0, ev.Old.Game.Players)
+	require.Equal(t, 5, ev.New.Game.Players)
+
+	require.NoError(t, g.Close())
+	ev = next()
+	require.Equal(t, xwis.RoomRemoved, ev.Type)
+	require.Nil(t, ev.New)
+	require.Equal(t, "Test", ev.Room().Name)
+
+	cancel()
+	for range w.Events() {
+	}
+	// the error must be available as soon as events are closed
+	require.True(t, errors.Is(w.Err(), context.Canceled), "%v", w.Err())
+	<-w.Done()
+}
+
+func TestFakeContext(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// default timeout, so the drain of the cancelled list must be bounded separately
+	cli, err := xwis.NewClientWithAddress(ctx, srv.Addr(), "testserv", "")
+	require.NoError(t, err)
+	defer cli.Close()
+
+	srv.IgnoreCommand("LIST")
+	lctx, lcancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = cli.ListRooms(lctx)
+	lcancel()
+	require.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+
+	_, err = cli.ListRooms(ctx)
+	require.NoError(t, err)
+
+	lctx, lcancel = context.WithCancel(ctx)
+	lcancel()
+	_, err = cli.RegisterGame(lctx, xwis.GameInfo{Name: "Test"})
+	require.True(t, errors.Is(err, context.Canceled), "%v", err)
+	require.NoError(t, cli.Err())
+
+	srv.IgnoreCommand("JOINGAME")
+	lctx, lcancel = context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = cli.RegisterGame(lctx, xwis.GameInfo{Name: "Test"})
+	lcancel()
+	require.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+	require.NoError(t, cli.Err())
+
+	// the channel is created after the request is cancelled, and must be left
+	srv.DelayCommand("JOINGAME", 200*time.Millisecond)
+	lctx, lcancel = context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = cli.RegisterGame(lctx, xwis.GameInfo{Name: "Test"})
+	lcancel()
+	require.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+	// the server handles commands in order, so PART is processed before the list
+	_, err = cli.ListRooms(ctx)
+	require.NoError(t, err)
+	require.Empty(t, srv.Games())
+	require.NoError(t, cli.Err())
+}
+
+func TestFakeStats(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	events := make(chan xwis.ReconnectEvent, 10)
+	cli, err := xwis.NewReconnectingClient(ctx, srv.Addr(), "testserv", "", xwis.ReconnectConfig{
+		MinDelay: time.Millisecond,
+		OnEvent: func(ev xwis.ReconnectEvent) {
+			events <- ev
+		},
+	})
+	require.NoError(t, err)
+	defer cli.Close()
+
+	g, err := cli.RegisterGame(ctx, xwis.GameInfo{
+		Name:       "Test Server",
+		Map:        "headache",
+		MapType:    xwis.MapTypeArena,
+		MaxPlayers: 31,
+	})
+	require.NoError(t, err)
+	defer g.Close()
+
+	_, err = cli.ListRooms(ctx)
+	require.NoError(t, err)
+
+	srv.SetTopic(srv.Games()[0].Channel, "garbage")
+	rooms, err := cli.ListRooms(ctx)
+	require.NoError(t, err)
+	require.Len(t, rooms, 1)
+	require.Nil(t, rooms[0].Game)
+
+	srv.FailCommand("LIST", "403")
+	_, err = cli.ListRooms(ctx)
+	require.Error(t, err)
+
+	st := cli.Stats()
+	require.Equal(t, uint64(2), st.ListRequests)
+	require.Equal(t, uint64(1), st.ListErrors)
+	require.Equal(t, uint64(1), st.DecodeFailures)
+	require.Equal(t, uint64(0), st.Reconnects)
+	require.True(t, st.ListDuration > 0)
+
+	srv.Disconnect("testserv")
+	<-events
+	ev := <-events
+	require.True(t, ev.Connected)
+	require.Equal(t, uint64(1), cli.Stats().Reconnects)
+}
+
+func TestFakeStrictGameInfo(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	cli, err := xwis.NewClientWithAddress(ctx, srv.Addr(), "testserv", "", xwis.WithStrictGameInfo())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	info := xwis.GameInfo{
+		Name:       "Test Server With Long Name",
+		Map:        "headache",
+		MapType:    xwis.MapTypeArena,
+		MaxPlayers: 32,
+	}
+	_, err = cli.RegisterGame(ctx, info)
+	var verr xwis.ValidationError
+	require.True(t, errors.As(err, &verr), "%v", err)
+	require.Len(t, verr, 2)
+	require.Empty(t, srv.Games())
+
+	info.Name = "Test Server"
+	info.MaxPlayers = 31
+	g, err := cli.RegisterGame(ctx, info)
+	require.NoError(t, err)
+	defer g.Close()
+
+	info.Players = 40
+	err = g.Update(ctx, info)
+	require.True(t, errors.As(err, &verr), "%v", err)
+}
+
+func TestFakeCodePage(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+	// the server stores names in the code page of the clients
+	lobby, err := xwis.CP1251.EncodeString("#Лобби")
+	require.NoError(t, err)
+	srv.AddChatRoom(lobby, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	newClient := func(login string) *xwis.Client {
+		cli, err := xwis.NewClientWithAddress(ctx, srv.Addr(), login, "", xwis.WithCodePage(xwis.CP1251))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = cli.Close()
+		})
+		return cli
+	}
+	host := newClient("host")
+	cli := newClient("player")
+
+	g, err := host.RegisterGame(ctx, xwis.GameInfo{
+		Name:       "Сервер",
+		Map:        "Карта",
+		MapType:    xwis.MapTypeArena,
+		MaxPlayers: 31,
+	})
+	require.NoError(t, err)
+	defer g.Close()
+
+	// make sure the server received the topic
+	_, err = host.ListRooms(ctx)
+	require.NoError(t, err)
+	rooms, err := cli.ListRooms(ctx)
+	require.NoError(t, err)
+
+	games := srv.Games()
+	require.Len(t, games, 1)
+	require.Equal(t, "\xd1\xe5\xf0\xe2\xe5\xf0", games[0].Info.Name)
+
+	require.Len(t, rooms, 2)
+	for _, r := range rooms {
+		if r.Game == nil {
+			require.Equal(t, "#Лобби", r.ID)
+			continue
+		}
+		require.Equal(t, "Сервер", r.Game.Name)
+		require.Equal(t, "Карта", r.Game.Map)
+	}
+
+	ch1, err := host.JoinChannel(ctx, "#Лобби")
+	require.NoError(t, err)
+	defer ch1.Close()
+	ch2, err := cli.JoinChannel(ctx, "#Лобби")
+	require.NoError(t, err)
+	defer ch2.Close()
+
+	err = ch1.Send(ctx, "Привет")
+	require.NoError(t, err)
+	select {
+	case m := <-cli.Messages():
+		require.Equal(t, xwis.ChatMessage{From: "host", Channel: "#Лобби", Text: "Привет"}, m)
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+
+	err = ch1.Send(ctx, "한국")
+	require.Error(t, err)
+}
+
+func TestFakeRecordReplay(t *testing.T) {
+	srv := xwistest.NewServer()
+	defer srv.Close()
+	srv.AddChatRoom("#Lob_37_0", 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	other := newTestClient(t, srv, "other")
+	och, err := other.JoinChannel(ctx, "#Lob_37_0")
+	require.NoError(t, err)
+	defer och.Close()
+
+	// session runs the same scenario on a live server and on the replayed transcript
+	session := func(cli *xwis.Client, live bool) ([]xwis.Room, xwis.ChatMessage) {
+		rooms, err := cli.ListRooms(ctx)
+		require.NoError(t, err)
+		ch, err := cli.JoinChannel(ctx, "#Lob_37_0")
+		require.NoError(t, err)
+		if live {
+			err = och.Send(ctx, "hello")
+			require.NoError(t, err)
+		}
+		var msg xwis.ChatMessage
+		select {
+		case msg = <-cli.Messages():
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+		err = ch.Send(ctx, "hi")
+		require.NoError(t, err)
+		err = cli.Close()
+		require.NoError(t, err)
+		return rooms, msg
+	}
+
+	var buf bytes.Buffer
+	rec := xwis.NewRecorder(&buf)
+	cli, err := xwis.NewClientWithAddress(ctx, srv.Addr(), "testserv", "", xwis.WithWireHook(rec.Record))
+	require.NoError(t, err)
+	rooms1, msg1 := session(cli, true)
+	require.NoError(t, rec.Err())
+	require.Equal(t, "hello", msg1.Text)
+
+	entries, err := xwis.ReadTranscript(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, xwis.Sent, entries[0].Dir)
+	require.Equal(t, "CVERS 11015 9472", entries[0].Line)
+
+	rep := xwis.NewReplayer(entries)
+	cli, err = xwis.NewClientWithAddress(ctx, srv.Addr(), "testserv", "", xwis.WithDialContext(rep.Dial))
+	require.NoError(t, err)
+	rooms2, msg2 := session(cli, false)
+	<-rep.Done()
+	require.NoError(t, rep.Err())
+	require.Equal(t, rooms1, rooms2)
+	require.Equal(t, msg1, msg2)
+
+	// a different scenario does not match the transcript
+	rep = xwis.NewReplayer(entries)
+	cli, err = xwis.NewClientWithAddress(ctx, srv.Addr(), "testserv", "", xwis.WithDialContext(rep.Dial))
+	require.NoError(t, err)
+	_, _ = cli.JoinChannel(ctx, "#Lob_37_1")
+	_ = cli.Close()
+	<-rep.Done()
+	require.True(t, errors.Is(rep.Err(), xwis.ErrReplayMismatch), "%v", rep.Err())
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/noxworld-dev/xwis"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "exporter",
+		Short: "Export lobby activity as Prometheus metrics",
+	}
+	Root.AddCommand(cmd)
+	fListen := cmd.Flags().String("listen", ":9150", "address to listen on")
+	fTimeout := cmd.Flags().Duration("timeout", time.Second*10, "room list timeout for a single scrape")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		rctx := cmd.Context()
+
+		ctx, cancel := context.WithTimeout(rctx, time.Minute/2)
+		cli, err := newClient(ctx,
+			xwis.WithReconnect(xwis.ReconnectConfig{
+				OnEvent: func(ev xwis.ReconnectEvent) {
+					log.Printf("reconnect: attempt=%d connected=%v err=%v", ev.Attempt, ev.Connected, ev.Err)
+				},
+			}),
+			xwis.WithKeepAlive(time.Minute, time.Minute),
+		)
+		cancel()
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+
+		cmd.SilenceUsage = true
+
+		e := &exporter{cli: cli, timeout: *fTimeout}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", e)
+		srv := &http.Server{Addr: *fListen, Handler: mux}
+		go func() {
+			<-rctx.Done()
+			_ = srv.Close()
+		}()
+		log.Printf("serving metrics on %s", *fListen)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	}
+}
+
+// exporter lists rooms on each scrape and writes metrics in Prometheus text format.
+type exporter struct {
+	cli     *xwis.Client
+	timeout time.Duration
+}
+
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), e.timeout)
+	defer cancel()
+	rooms, err := e.cli.ListRooms(ctx)
+	if err != nil {
+		log.Printf("cannot list rooms: %v", err)
+	}
+	var buf bytes.Buffer
+	writeMetrics(&buf, rooms, err == nil, e.cli.Stats())
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+func writeMetrics(w io.Writer, rooms []xwis.Room, up bool, st xwis.Stats) {
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].ID < rooms[j].ID
+	})
+	var games, players, chatUsers int
+	for _, r := range rooms {
+		if r.Game != nil {
+			games++
+			players += r.Game.Players
+		} else if r.Kind != xwis.RoomGame {
+			chatUsers += r.Users
+		}
+	}
+
+	writeHeader(w, "xwis_up", "gauge", "Whether the last room list request succeeded.")
+	writeSample(w, "xwis_up", nil, boolValue(up))
+
+	if up {
+		writeHeader(w, "xwis_games", "gauge", "Number of games in the lobby.")
+		writeSample(w, "xwis_games", nil, float64(games))
+
+		writeHeader(w, "xwis_players", "gauge", "Number of players in all games.")
+		writeSample(w, "xwis_players", nil, float64(players))
+
+		writeHeader(w, "xwis_chat_users", "gauge", "Number of users in all chat rooms.")
+		writeSample(w, "xwis_chat_users", nil, float64(chatUsers))
+
+		writeHeader(w, "xwis_game_players", "gauge", "Number of players in the game.")
+		for _, r := range rooms {
+			if g := r.Game; g != nil {
+				writeSample(w, "xwis_game_players", []string{
+					"id", r.ID, "name", g.Name, "map", g.Map, "map_type", g.MapType.String(),
+				}, float64(g.Players))
+			}
+		}
+
+		writeHeader(w, "xwis_game_max_players", "gauge", "Max number of players in the game.")
+		for _, r := range rooms {
+			if g := r.Game; g != nil {
+				writeSample(w, "xwis_game_max_players", []string{
+					"id", r.ID, "name", g.Name, "map", g.Map, "map_type", g.MapType.String(),
+				}, float64(g.MaxPlayers))
+			}
+		}
+
+		writeHeader(w, "xwis_chat_room_users", "gauge", "Number of users in the chat room.")
+		for _, r := range rooms {
+			if r.Kind != xwis.RoomGame {
+				writeSample(w, "xwis_chat_room_users", []string{"id", r.ID, "name", r.Name}, float64(r.Users))
+			}
+		}
+	}
+
+	writeHeader(w, "xwis_reconnects_total", "counter", "Number of successful reconnects to the lobby server.")
+	writeSample(w, "xwis_reconnects_total", nil, float64(st.Reconnects))
+
+	writeHeader(w, "xwis_list_errors_total", "counter", "Number of failed room list requests.")
+	writeSample(w, "xwis_list_errors_total", nil, float64(st.ListErrors))
+
+	writeHeader(w, "xwis_list_duration_seconds", "summary", "Latency of successful room list requests.")
+	writeSample(w, "xwis_list_duration_seconds_sum", nil, st.ListDuration.Seconds())
+	writeSample(w, "xwis_list_duration_seconds_count", nil, float64(st.ListRequests))
+
+	writeHeader(w, "xwis_decode_failures_total", "counter", "Number of game payloads that could not be decoded.")
+	writeSample(w, "xwis_decode_failures_total", nil, float64(st.DecodeFailures))
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeSample writes a single sample. Labels are given as name-value pairs.
+func writeSample(w io.Writer, name string, labels []string, v float64) {
+	var sb strings.Builder
+	sb.WriteString(name)
+	if len(labels) != 0 {
+		sb.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i != 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(labels[i])
+			sb.WriteString(`="`)
+			// names are raw code page bytes without --codepage, and must not break the text format
+			sb.WriteString(labelEscaper.Replace(strings.ToValidUTF8(labels[i+1], "\uFFFD")))
+			sb.WriteByte('"')
+		}
+		sb.WriteByte('}')
+	}
+	fmt.Fprintf(w, "%s %g\n", sb.String(), v)
+}
+
+func boolValue(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noxworld-dev/xwis"
+)
+
+func TestWriteMetricsInvalidUTF8(t *testing.T) {
+	rooms := []xwis.Room{
+		{ID: "#Lob_37_0", Name: "Brin", Kind: xwis.RoomLobby, Users: 2},
+		{ID: "#host's_game", Name: "\xd2\xe5\xf1\xf2", Kind: xwis.RoomGame, Users: 1, Game: &xwis.GameInfo{
+			Name: "\xd2\xe5\xf1\xf2", Map: "estate\xff", MapType: xwis.MapTypeArena, Players: 1, MaxPlayers: 16,
+		}},
+	}
+	var buf bytes.Buffer
+	writeMetrics(&buf, rooms, true, xwis.Stats{})
+	require.True(t, utf8.Valid(buf.Bytes()), "%q", buf.String())
+	require.Contains(t, buf.String(), `xwis_game_players{id="#host's_game",name="�",map="estate�",map_type="arena"} 1`)
+	require.Contains(t, buf.String(), `xwis_chat_users 2`)
+}
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/noxworld-dev/xwis"
 	"github.com/spf13/cobra"
 )
 
@@ -16,6 +17,7 @@ func init() {
 	Root.AddCommand(cmd)
 	fChats := cmd.Flags().Bool("chat", false, "list chat rooms")
 	fInterval := cmd.Flags().Duration("t", time.Second*3, "refresh interval")
+	fChanges := cmd.Flags().Bool("changes", false, "print only changes in the room list")
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		rctx := cmd.Context()
 
@@ -30,6 +32,9 @@ func init() {
 		cmd.SilenceUsage = true
 
 		fmt.Println("Connected!")
+		if *fChanges {
+			return watchRooms(rctx, cli, *fInterval, *fChats)
+		}
 		ticker := time.NewTicker(*fInterval)
 		defer ticker.Stop()
 		for {
@@ -67,3 +72,35 @@ func init() {
 		}
 	}
 }
+
+func formatRoom(r *xwis.Room) string {
+	if g := r.Game; g != nil {
+		return fmt.Sprintf("%s\t%d/%d\t%s\t%s", g.Name, g.Players, g.MaxPlayers, g.Map, g.MapType)
+	}
+	return fmt.Sprintf("%s\t%d", r.Name, r.Users)
+}
+
+func watchRooms(ctx context.Context, cli *xwis.Client, interval time.Duration, chats bool) error {
+	w, err := cli.WatchRooms(ctx, interval)
+	if err != nil {
+		return err
+	}
+	for ev := range w.Events() {
+		if ev.Room().Game == nil && !chats {
+			continue
+		}
+		ts := time.Now().Format("2006-01-02 15:04:05")
+		switch ev.Type {
+		case xwis.RoomAdded:
+			fmt.Printf("%s\t+ %s\n", ts, formatRoom(ev.New))
+		case xwis.RoomRemoved:
+			fmt.Printf("%s\t- %s\n", ts, formatRoom(ev.Old))
+		case xwis.RoomChanged:
+			fmt.Printf("%s\t* %s\t(was: %s)\n", ts, formatRoom(ev.New), formatRoom(ev.Old))
+		}
+	}
+	if err := w.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 
 	"github.com/noxworld-dev/xwis"
@@ -16,10 +18,40 @@ var (
 	fRootHost = Root.PersistentFlags().String("host", xwis.DefaultAddress, "lobby server address")
 	fRootName = Root.PersistentFlags().String("login", "", "user login to use")
 	fRootPass = Root.PersistentFlags().String("pass", "", "user password to use")
+	fRootCP   = Root.PersistentFlags().String("codepage", "", "code page for names and chat: cp1252, cp1251 or cp949")
+	fRootRec  = Root.PersistentFlags().String("record", "", "append a transcript of the protocol to a file")
 )
 
-func newClient(ctx context.Context) (*xwis.Client, error) {
-	return xwis.NewClientWithAddress(ctx, *fRootHost, *fRootName, *fRootPass)
+func codePage() (xwis.CodePage, error) {
+	return xwis.ParseCodePage(*fRootCP)
+}
+
+func newClient(ctx context.Context, opts ...xwis.Option) (*xwis.Client, error) {
+	cp, err := codePage()
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]xwis.Option{xwis.WithCodePage(cp)}, opts...)
+	if *fRootRec != "" {
+		// the file is closed on exit
+		f, err := os.OpenFile(*fRootRec, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, xwis.WithWireHook(xwis.NewRecorder(f).Record))
+	}
+	cli, err := xwis.NewClientWithAddress(ctx, *fRootHost, *fRootName, *fRootPass, opts...)
+	switch {
+	case errors.Is(err, xwis.ErrNickInUse):
+		return nil, fmt.Errorf("login %q is already in use; wait for the old session to expire or use a different --login: %w", *fRootName, err)
+	case errors.Is(err, xwis.ErrBadNick):
+		return nil, fmt.Errorf("login %q is not allowed; use a different --login: %w", *fRootName, err)
+	case errors.Is(err, xwis.ErrBadPassword):
+		return nil, fmt.Errorf("wrong password for login %q; check the --pass flag: %w", *fRootName, err)
+	case errors.Is(err, xwis.ErrBanned):
+		return nil, fmt.Errorf("login %q is banned on %s: %w", *fRootName, *fRootHost, err)
+	}
+	return cli, err
 }
 
 func main() {
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/noxworld-dev/xwis"
+	"github.com/spf13/cobra"
+	"gopkg.in/irc.v3"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "decode [payload]",
+		Short: "Decode the game info payload from a 326 line, TOPIC command or the payload itself",
+		Args:  cobra.MaximumNArgs(1),
+	}
+	Root.AddCommand(cmd)
+	fFormat := cmd.Flags().String("format", "auto", "input format: auto, raw, escaped or hex")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		input, err := readInput(args)
+		if err != nil {
+			return err
+		}
+		data, err := parseInput(input, *fFormat)
+		if err != nil {
+			return err
+		}
+		cp, err := codePage()
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		h, g, err := decodeGameInfo(data)
+		if err != nil {
+			return err
+		}
+		g.Name = cp.DecodeString(g.Name)
+		g.Map = cp.DecodeString(g.Map)
+		out := newGameInfoJSON(g)
+		out.Header = h
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(out)
+	}
+}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "encode [file.json]",
+		Short: "Encode the game info from JSON into the TOPIC payload",
+		Args:  cobra.MaximumNArgs(1),
+	}
+	Root.AddCommand(cmd)
+	fFormat := cmd.Flags().String("format", "escaped", "output format: raw, escaped or hex")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		var data []byte
+		var err error
+		if len(args) == 0 || args[0] == "-" {
+			data, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			data, err = ioutil.ReadFile(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		var in gameInfoJSON
+		if err := json.Unmarshal(data, &in); err != nil {
+			return err
+		}
+		g, err := in.gameInfo()
+		if err != nil {
+			return err
+		}
+		cp, err := codePage()
+		if err != nil {
+			return err
+		}
+		if err := g.ValidateCodePage(cp); err != nil {
+			return err
+		}
+		if g.Name, err = cp.EncodeString(g.Name); err != nil {
+			return err
+		}
+		if g.Map, err = cp.EncodeString(g.Map); err != nil {
+			return err
+		}
+		payload, err := xwis.EncodePayload(g)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		switch *fFormat {
+		case "raw":
+			_, err = os.Stdout.Write(payload)
+			return err
+		case "escaped":
+			_, err = fmt.Println(strconv.Quote(string(payload)))
+			return err
+		case "hex":
+			_, err = fmt.Println(hex.EncodeToString(payload))
+			return err
+		default:
+			return fmt.Errorf("unsupported format: %q", *fFormat)
+		}
+	}
+}
+
+// gameInfoJSON includes fields of GameInfo that are not encoded to JSON by default.
+type gameInfoJSON struct {
+	Header *xwis.PayloadHeader `json:"header,omitempty"`
+	*xwis.GameInfo
+	Unk1    string `json:"unk1"` // hex, for example 0x01
+	Unk2    string `json:"unk2"` // hex, for example 0x0001
+	Unknown string `json:"unknown"`
+}
+
+func newGameInfoJSON(g *xwis.GameInfo) gameInfoJSON {
+	return gameInfoJSON{
+		GameInfo: g,
+		Unk1:     fmt.Sprintf("0x%02x", g.Unk1),
+		Unk2:     fmt.Sprintf("0x%04x", g.Unk2),
+		Unknown:  hex.EncodeToString(g.Unknown),
+	}
+}
+
+// gameInfo returns the game info with unknown fields set. Zero values are replaced by defaults when encoding.
+func (v *gameInfoJSON) gameInfo() (*xwis.GameInfo, error) {
+	g := v.GameInfo
+	if g == nil {
+		g = new(xwis.GameInfo)
+	}
+	if v.Unk1 != "" {
+		u, err := parseHexUint(v.Unk1, 8)
+		if err != nil {
+			return nil, fmt.Errorf("unk1: %w", err)
+		}
+		g.Unk1 = byte(u)
+	}
+	if v.Unk2 != "" {
+		u, err := parseHexUint(v.Unk2, 16)
+		if err != nil {
+			return nil, fmt.Errorf("unk2: %w", err)
+		}
+		g.Unk2 = uint16(u)
+	}
+	if v.Unknown != "" {
+		data, err := hex.DecodeString(v.Unknown)
+		if err != nil {
+			return nil, fmt.Errorf("unknown: %w", err)
+		}
+		g.Unknown = data
+	}
+	return g, nil
+}
+
+// parseHexUint parses a hex value with an optional 0x prefix, as written by newGameInfoJSON.
+func parseHexUint(s string, bits int) (uint64, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	return strconv.ParseUint(s, 16, bits)
+}
+
+// readInput returns the first argument, or reads the input from stdin.
+func readInput(args []string) (string, error) {
+	if len(args) != 0 && args[0] != "-" {
+		return args[0], nil
+	}
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// parseInput converts the input in a given format to raw bytes. In auto mode, quoted strings and strings with
+// escape sequences are unquoted, and strings consisting only of hex digits and spaces are decoded from hex.
+func parseInput(s string, format string) ([]byte, error) {
+	s = strings.TrimRight(s, "\r\n")
+	if format != "raw" {
+		// raw payload has no spaces, but the line may
+		s = strings.TrimSpace(s)
+	}
+	if format == "auto" {
+		switch {
+		case strings.HasPrefix(s, `"`):
+			format = "escaped"
+		case isHex(strings.Join(strings.Fields(s), "")):
+			format = "hex"
+		case strings.Contains(s, `\`):
+			format = "escaped"
+		default:
+			format = "raw"
+		}
+	}
+	switch format {
+	case "raw":
+		return []byte(s), nil
+	case "hex":
+		s = strings.Join(strings.Fields(s), "")
+		return hex.DecodeString(s)
+	case "escaped":
+		if !strings.HasPrefix(s, `"`) {
+			s = `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+		}
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid escaped string: %w", err)
+		}
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %q", format)
+	}
+}
+
+func isHex(s string) bool {
+	if s == "" || len(s)%2 != 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'f', c >= 'A' && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// decodeGameInfo decodes the game info from a 326 line, TOPIC command, the payload with the pre-header,
+// or the topic value. The header is only returned for payloads with the pre-header.
+func decodeGameInfo(data []byte) (*xwis.PayloadHeader, *xwis.GameInfo, error) {
+	// encrypted payload never contains spaces, so it's an IRC line
+	if bytes.IndexByte(data, ' ') >= 0 {
+		m, err := irc.ParseMessage(string(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case m.Command == "326" && len(m.Params) == 9:
+			data = []byte(m.Params[8])
+		case m.Command == "TOPIC" && len(m.Params) == 2:
+			data = []byte(m.Params[1])
+		default:
+			return nil, nil, errors.New("expected a 326 reply or a TOPIC command")
+		}
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte(":G1P3")):
+		g, err := xwis.DecodeTopic(data[1:])
+		return nil, g, err
+	case bytes.HasPrefix(data, []byte("G1P3")):
+		g, err := xwis.DecodeTopic(data)
+		return nil, g, err
+	}
+	h, err := xwis.ParsePayloadHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	g, err := xwis.DecodePayload(data)
+	return h, g, err
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noxworld-dev/xwis"
+)
+
+func TestGameInfoJSONUnknown(t *testing.T) {
+	data, err := json.Marshal(newGameInfoJSON(&xwis.GameInfo{Name: "game", Unk1: 0x1, Unk2: 0xab}))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"unk1":"0x01","unk2":"0x00ab"`)
+
+	var in gameInfoJSON
+	require.NoError(t, json.Unmarshal(data, &in))
+	g, err := in.gameInfo()
+	require.NoError(t, err)
+	require.Equal(t, byte(0x1), g.Unk1)
+	require.Equal(t, uint16(0xab), g.Unk2)
+
+	in = gameInfoJSON{Unk1: "0x100"}
+	_, err = in.gameInfo()
+	require.Error(t, err)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/noxworld-dev/xwis"
+	"github.com/spf13/cobra"
+	"gopkg.in/irc.v3"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "proxy",
+		Short: "Proxy the game client to the lobby server and decode the traffic",
+	}
+	Root.AddCommand(cmd)
+	fListen := cmd.Flags().String("listen", "127.0.0.1:4000", "address to listen on")
+	fColor := cmd.Flags().Bool("color", isTerminal(os.Stdout), "highlight changed bytes with colors")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		rctx := cmd.Context()
+		l, err := net.Listen("tcp", *fListen)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		go func() {
+			<-rctx.Done()
+			_ = l.Close()
+		}()
+		cmd.SilenceUsage = true
+		log.Printf("proxying %s -> %s", *fListen, *fRootHost)
+		p := &proxy{
+			addr:  *fRootHost,
+			color: *fColor,
+			out:   os.Stdout,
+			last:  make(map[string][]byte),
+		}
+		for id := 1; ; id++ {
+			conn, err := l.Accept()
+			if err != nil {
+				if rctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			go p.handle(id, conn)
+		}
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return st.Mode()&os.ModeCharDevice != 0
+}
+
+// proxy forwards connections to the lobby server and logs all lines, decoding game info payloads.
+type proxy struct {
+	addr  string
+	color bool
+
+	mu   sync.Mutex
+	out  io.Writer
+	last map[string][]byte // last decrypted payload per channel
+}
+
+func (p *proxy) printf(format string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, format, args...)
+}
+
+func (p *proxy) handle(id int, conn net.Conn) {
+	defer conn.Close()
+	p.printf("[%d] connected from %s\n", id, conn.RemoteAddr())
+	up, err := net.Dial("tcp", p.addr)
+	if err != nil {
+		p.printf("[%d] cannot connect to %s: %v\n", id, p.addr, err)
+		return
+	}
+	defer up.Close()
+	done := make(chan struct{}, 2)
+	go p.pipe(id, xwis.Sent, up, conn, done)
+	go p.pipe(id, xwis.Received, conn, up, done)
+	<-done
+	p.printf("[%d] disconnected\n", id)
+}
+
+// pipe forwards lines from src to dst, and logs them.
+func (p *proxy) pipe(id int, dir xwis.Direction, dst, src net.Conn, done chan<- struct{}) {
+	defer func() {
+		_ = dst.Close()
+		_ = src.Close()
+		done <- struct{}{}
+	}()
+	br := bufio.NewReader(src)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			if _, werr := io.WriteString(dst, line); werr != nil {
+				return
+			}
+			p.inspect(id, dir, strings.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// inspect logs the line and decodes game info from TOPIC commands, topic replies and room list replies.
+func (p *proxy) inspect(id int, dir xwis.Direction, line string) {
+	p.printf("[%d] %s %s\n", id, dir, printableLine(line))
+	m, err := irc.ParseMessage(line)
+	if err != nil {
+		return
+	}
+	var channel, data string
+	switch {
+	case dir == xwis.Sent && m.Command == "TOPIC" && len(m.Params) == 2:
+		channel, data = m.Params[0], m.Params[1]
+	case dir == xwis.Received && m.Command == "326" && len(m.Params) == 9:
+		channel, data = m.Params[1], m.Params[8]
+	case dir == xwis.Received && m.Command == "332" && len(m.Params) == 3:
+		// topic of the game channel, sent on join
+		channel, data = m.Params[1], m.Params[2]
+	default:
+		return
+	}
+	info, raw, err := xwis.DecryptGameInfo([]byte(data))
+	if err != nil {
+		if m.Command == "332" {
+			// chat channels have plain text topics
+			return
+		}
+		p.printf("[%d]   cannot decode game info for %s: %v\n", id, channel, err)
+		return
+	}
+	p.printGameInfo(id, dir, channel, info, raw)
+}
+
+// printGameInfo prints the decoded game info and a hex dump of the decrypted payload.
+func (p *proxy) printGameInfo(id int, dir xwis.Direction, channel string, info *xwis.GameInfo, bin []byte) {
+	data, err := json.Marshal(newGameInfoJSON(info))
+	if err != nil {
+		p.printf("[%d]   cannot encode game info: %v\n", id, err)
+		return
+	}
+	key := dir.String() + channel
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prev := p.last[key]
+	p.last[key] = bin
+	fmt.Fprintf(p.out, "[%d]   game info for %s: %s\n", id, channel, data)
+	p.hexDump(bin, prev)
+}
+
+// hexDump writes a hex dump of data, highlighting bytes that are different from prev.
+// It must be called with the lock held.
+func (p *proxy) hexDump(data, prev []byte) {
+	const width = 16
+	for off := 0; off < len(data); off += width {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "      %04x ", off)
+		for i := off; i < off+width && i < len(data); i++ {
+			b := fmt.Sprintf("%02x", data[i])
+			changed := prev != nil && (i >= len(prev) || prev[i] != data[i])
+			switch {
+			case !changed:
+				sb.WriteString(" " + b + " ")
+			case p.color:
+				sb.WriteString(" \x1b[1;31m" + b + "\x1b[0m ")
+			default:
+				sb.WriteString("[" + b + "]")
+			}
+		}
+		fmt.Fprintln(p.out, sb.String())
+	}
+}
+
+// printableLine quotes the line if it contains non-printable characters.
+func printableLine(line string) string {
+	for i := 0; i < len(line); i++ {
+		if c := line[i]; c < 0x20 || c >= 0x7f {
+			return fmt.Sprintf("%q", line)
+		}
+	}
+	return line
+}
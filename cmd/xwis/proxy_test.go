@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noxworld-dev/xwis"
+)
+
+func TestProxyInspect(t *testing.T) {
+	payload, err := xwis.EncodePayload(&xwis.GameInfo{Name: "test", Map: "estate", MapType: xwis.MapTypeArena})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	p := &proxy{out: &buf, last: make(map[string][]byte)}
+	p.inspect(1, xwis.Sent, "TOPIC #host's_game "+string(payload))
+	p.inspect(1, xwis.Received, ":xwis 332 user #host's_game "+string(payload))
+	p.inspect(1, xwis.Received, ":xwis 332 user #Lob_37_0 :Welcome")
+	out := buf.String()
+	require.Equal(t, 2, strings.Count(out, `game info for #host's_game: {`), out)
+	require.NotContains(t, out, "cannot decode")
+}
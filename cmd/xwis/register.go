@@ -19,6 +19,7 @@ func init() {
 	}
 	Root.AddCommand(cmd)
 	fConf := cmd.Flags().StringP("config", "c", "xwis-game.json", "game config")
+	fKeepAlive := cmd.Flags().Duration("keepalive", time.Minute, "keepalive interval; zero disables it")
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		rctx := cmd.Context()
 
@@ -52,14 +53,30 @@ func init() {
 		if err := json.Unmarshal(data, &g); err != nil {
 			return err
 		}
+		cp, err := codePage()
+		if err != nil {
+			return err
+		}
+		if err := g.ValidateCodePage(cp); err != nil {
+			cmd.SilenceUsage = true
+			var verr xwis.ValidationError
+			if !errors.As(err, &verr) {
+				return err
+			}
+			for _, f := range verr {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", *fConf, f)
+			}
+			return fmt.Errorf("invalid config: %q", *fConf)
+		}
 
 		ctx, cancel := context.WithTimeout(rctx, time.Minute/2)
-		cli, err := newClient(ctx)
+		cli, err := newClient(ctx, xwis.WithStrictGameInfo())
 		cancel()
 		if err != nil {
 			return err
 		}
 		defer cli.Close()
+		cli.KeepAlive(*fKeepAlive, *fKeepAlive)
 
 		cmd.SilenceUsage = true
 
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noxworld-dev/xwis"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the room list as JSON over HTTP",
+	}
+	Root.AddCommand(cmd)
+	fListen := cmd.Flags().String("listen", ":8080", "address to listen on")
+	fInterval := cmd.Flags().Duration("t", time.Second*5, "refresh interval")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		rctx := cmd.Context()
+
+		ctx, cancel := context.WithTimeout(rctx, time.Minute/2)
+		cli, err := newClient(ctx,
+			xwis.WithReconnect(xwis.ReconnectConfig{
+				OnEvent: func(ev xwis.ReconnectEvent) {
+					log.Printf("reconnect: attempt=%d connected=%v err=%v", ev.Attempt, ev.Connected, ev.Err)
+				},
+			}),
+			xwis.WithKeepAlive(time.Minute, time.Minute),
+		)
+		cancel()
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+
+		cmd.SilenceUsage = true
+
+		s := &roomServer{cli: cli, interval: *fInterval}
+		if err := s.refresh(rctx); err != nil {
+			return err
+		}
+		go s.refreshLoop(rctx)
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/v1/rooms", s.handleRooms)
+		mux.HandleFunc("/api/v1/games", s.handleGames)
+		srv := &http.Server{Addr: *fListen, Handler: mux}
+		go func() {
+			<-rctx.Done()
+			_ = srv.Close()
+		}()
+		log.Printf("serving on %s", *fListen)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	}
+}
+
+// roomServer keeps a cached room list and serves it over HTTP.
+type roomServer struct {
+	cli      *xwis.Client
+	interval time.Duration
+
+	mu      sync.RWMutex
+	rooms   []xwis.Room
+	modTime time.Time
+}
+
+func (s *roomServer) refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+	list, err := s.cli.ListRooms(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, _ := json.Marshal(s.rooms)
+	cur, _ := json.Marshal(list)
+	if !bytes.Equal(old, cur) {
+		s.modTime = time.Now().UTC().Truncate(time.Second)
+	}
+	s.rooms = list
+	return nil
+}
+
+func (s *roomServer) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("cannot refresh the room list: %v", err)
+		}
+	}
+}
+
+func (s *roomServer) snapshot() ([]xwis.Room, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rooms, s.modTime
+}
+
+// roomFilter selects rooms based on query parameters.
+type roomFilter struct {
+	games      bool
+	name       string
+	mapName    string
+	mapType    *xwis.MapType
+	access     *xwis.Access
+	minPlayers int
+}
+
+func parseRoomFilter(r *http.Request, games bool) (*roomFilter, error) {
+	q := r.URL.Query()
+	f := &roomFilter{
+		games:   games,
+		name:    strings.ToLower(q.Get("name")),
+		mapName: strings.ToLower(q.Get("map")),
+	}
+	if v := q.Get("map_type"); v != "" {
+		var t xwis.MapType
+		if err := t.UnmarshalJSON([]byte(strconv.Quote(v))); err != nil {
+			return nil, err
+		}
+		f.mapType = &t
+	}
+	if v := q.Get("access"); v != "" {
+		var a xwis.Access
+		if err := a.UnmarshalJSON([]byte(strconv.Quote(v))); err != nil {
+			return nil, err
+		}
+		f.access = &a
+	}
+	if v := q.Get("min_players"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_players: %w", err)
+		}
+		f.minPlayers = n
+	}
+	return f, nil
+}
+
+func (f *roomFilter) Match(r *xwis.Room) bool {
+	g := r.Game
+	if g == nil {
+		return !f.games && f.mapName == "" && f.mapType == nil && f.access == nil &&
+			r.Users >= f.minPlayers && strings.Contains(strings.ToLower(r.Name), f.name)
+	}
+	if !strings.Contains(strings.ToLower(g.Name), f.name) {
+		return false
+	}
+	if !strings.Contains(strings.ToLower(g.Map), f.mapName) {
+		return false
+	}
+	if f.mapType != nil && g.MapType != *f.mapType {
+		return false
+	}
+	if f.access != nil && g.Access != *f.access {
+		return false
+	}
+	return g.Players >= f.minPlayers
+}
+
+func (s *roomServer) handleRooms(w http.ResponseWriter, r *http.Request) {
+	s.serveRooms(w, r, false)
+}
+
+func (s *roomServer) handleGames(w http.ResponseWriter, r *http.Request) {
+	s.serveRooms(w, r, true)
+}
+
+func (s *roomServer) serveRooms(w http.ResponseWriter, r *http.Request, games bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	f, err := parseRoomFilter(r, games)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rooms, modTime := s.snapshot()
+	out := make([]xwis.Room, 0, len(rooms))
+	for i := range rooms {
+		if f.Match(&rooms[i]) {
+			out = append(out, rooms[i])
+		}
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sum := sha1.Sum(data)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+}
@@ -0,0 +1,154 @@
+package xwis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/korean"
+)
+
+var (
+	_ json.Marshaler   = CodePage(0)
+	_ json.Unmarshaler = (*CodePage)(nil)
+)
+
+// CodePage is a Windows code page used by the game to encode non-ASCII text: game, map and channel names, and chat.
+//
+// Zero value means that the text is sent as-is, without any conversion.
+type CodePage int
+
+const (
+	CodePageNone = CodePage(0)
+	CP1252       = CodePage(1252) // Western European
+	CP1251       = CodePage(1251) // Cyrillic
+	CP949        = CodePage(949)  // Korean
+)
+
+// ParseCodePage parses the code page name, e.g. "cp1251" or "1251".
+func ParseCodePage(s string) (CodePage, error) {
+	if s == "" || s == "none" {
+		return CodePageNone, nil
+	}
+	v := s
+	if len(v) > 2 && (v[:2] == "cp" || v[:2] == "CP") {
+		v = v[2:]
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("unsupported code page: %q", s)
+	}
+	cp := CodePage(n)
+	if cp.Unknown() {
+		return 0, fmt.Errorf("unsupported code page: %q", s)
+	}
+	return cp, nil
+}
+
+func (cp CodePage) Unknown() bool {
+	switch cp {
+	case CodePageNone, CP1252, CP1251, CP949:
+		return false
+	}
+	return true
+}
+
+func (cp CodePage) String() string {
+	if cp == CodePageNone {
+		return "none"
+	}
+	return "cp" + strconv.Itoa(int(cp))
+}
+
+func (cp CodePage) MarshalJSON() ([]byte, error) {
+	if cp.Unknown() {
+		return json.Marshal(int(cp))
+	}
+	return json.Marshal(cp.String())
+}
+
+func (cp *CodePage) UnmarshalJSON(data []byte) error {
+	var v int
+	err := json.Unmarshal(data, &v)
+	if err == nil {
+		*cp = CodePage(v)
+		return nil
+	}
+	var s string
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	*cp, err = ParseCodePage(s)
+	return err
+}
+
+func (cp CodePage) encoding() encoding.Encoding {
+	switch cp {
+	case CP1252:
+		return charmap.Windows1252
+	case CP1251:
+		return charmap.Windows1251
+	case CP949:
+		// EUC-KR implementation in x/text is the Unified Hangul Code, which is the same as cp949.
+		return korean.EUCKR
+	}
+	return nil
+}
+
+// EncodeString converts UTF-8 text to the code page.
+// It returns an error if the text contains characters that cannot be represented in the code page.
+func (cp CodePage) EncodeString(s string) (string, error) {
+	enc := cp.encoding()
+	if enc == nil || isASCII(s) {
+		return s, nil
+	}
+	out, err := enc.NewEncoder().String(s)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode %q to %v: %w", s, cp, err)
+	}
+	return out, nil
+}
+
+// DecodeString converts the text in the code page to UTF-8. Invalid sequences are replaced with U+FFFD.
+func (cp CodePage) DecodeString(s string) string {
+	enc := cp.encoding()
+	if enc == nil || isASCII(s) {
+		return s
+	}
+	out, err := enc.NewDecoder().String(s)
+	if err != nil {
+		return s
+	}
+	return out
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeGameInfo returns a copy of the game info with text fields converted to the code page.
+func (cp CodePage) encodeGameInfo(g *GameInfo) (*GameInfo, error) {
+	out := *g
+	var err error
+	if out.Name, err = cp.EncodeString(g.Name); err != nil {
+		return nil, err
+	}
+	if out.Map, err = cp.EncodeString(g.Map); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// decodeGameInfo converts text fields of the game info from the code page to UTF-8.
+func (cp CodePage) decodeGameInfo(g *GameInfo) {
+	g.Name = cp.DecodeString(g.Name)
+	g.Map = cp.DecodeString(g.Map)
+}
@@ -0,0 +1,51 @@
+package xwis
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodePage(t *testing.T) {
+	for _, c := range []struct {
+		cp   CodePage
+		text string
+		enc  string
+	}{
+		{CodePageNone, "Сервер", "Сервер"},
+		{CP1252, "Café", "Caf\xe9"},
+		{CP1251, "Сервер", "\xd1\xe5\xf0\xe2\xe5\xf0"},
+		{CP949, "한국", "\xc7\xd1\xb1\xb9"},
+	} {
+		t.Run(c.cp.String(), func(t *testing.T) {
+			enc, err := c.cp.EncodeString(c.text)
+			require.NoError(t, err)
+			require.Equal(t, c.enc, enc)
+			require.Equal(t, c.text, c.cp.DecodeString(enc))
+		})
+	}
+	_, err := CP1251.EncodeString("한국")
+	require.Error(t, err)
+}
+
+func TestParseCodePage(t *testing.T) {
+	for s, exp := range map[string]CodePage{
+		"":       CodePageNone,
+		"none":   CodePageNone,
+		"1251":   CP1251,
+		"cp1251": CP1251,
+		"CP949":  CP949,
+	} {
+		cp, err := ParseCodePage(s)
+		require.NoError(t, err, s)
+		require.Equal(t, exp, cp, s)
+	}
+	_, err := ParseCodePage("cp866")
+	require.Error(t, err)
+
+	var cp CodePage
+	err = json.Unmarshal([]byte(`"cp1252"`), &cp)
+	require.NoError(t, err)
+	require.Equal(t, CP1252, cp)
+}
@@ -1,91 +1,172 @@
 package xwis
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/noxworld-dev/xwis/pack7"
+)
+
 const (
-	preHeaderLength  = 4
-	headerLength     = 8
-	fullHeaderLength = preHeaderLength + headerLength
+	// headerLength is the length of the payload header, including the leading ':'.
+	headerLength = 8
+	// cryptOverhead is the number of extra bytes needed for the 8-to-7-bit packing of the game info:
+	// 69 bytes are encrypted into 78. Binary game info reserves the same space at the end (see GameInfo.Unknown).
+	cryptOverhead = 9
 )
 
+// ErrInvalidPayload is returned when the game info payload is malformed.
+var ErrInvalidPayload = errors.New("invalid game info payload")
+
+// maxPayloadLength is the length of payloads that can be encrypted and decrypted without allocations.
+const maxPayloadLength = 128
+
+// buffer returns a buffer of length n, using arr if it's large enough.
+func buffer(arr *[maxPayloadLength]byte, n int) []byte {
+	if n <= len(arr) {
+		return arr[:n]
+	}
+	return make([]byte, n)
+}
+
+// encryptTo encrypts the game info data into out, which must be of the same length.
+//
+// Only the first len(data)-cryptOverhead bytes are encrypted, the rest is the space for the 8-to-7-bit expansion.
+// Payloads sent by the game do not include the last partial group of bits, so it is dropped as well.
 func encryptTo(out, data []byte) []byte {
-	ind := 0
-	cnt := 0
-	loc := 0
-	loc2 := 0
-	acc := 0
-	for i := 0; i <= len(data)-10; i++ {
-		for j := 0; j <= 7; j++ {
-			if loc == 8 {
-				ind++
-				loc = 0
-			}
-			if loc2 == 7 {
-				acc ^= 1 << 7
-				out[cnt] = byte(acc)
-				cnt++
-				acc = 0
-				loc2 = 0
-			}
-			var v6 byte
-			if ind < len(data) {
-				v6 = data[ind]
-			}
-			v5 := 1 << loc
-			v4 := int(v6) & v5
-			v3 := v4 >> loc
-			acc ^= (v3 << loc2) & 0xFF
-			loc++
-			loc2++
-		}
+	n := len(data) - cryptOverhead
+	if n <= 0 {
+		return out
 	}
+	var arr [maxPayloadLength]byte
+	buf := buffer(&arr, pack7.EncodedLen(n))
+	pack7.Encode(buf, data[:n])
+	copy(out, buf[:n*8/7])
 	return out
 }
 
+// decrypt decrypts the game info payload in place. It is an inverse of encryptTo.
+//
+// The first len(data)-cryptOverhead bytes are decrypted, and the rest is set to zero. The last partial group of bits,
+// that is not sent by the game, is assumed to be zero.
 func decrypt(data []byte) {
-	ind := 0
-	cnt := 0
-	loc := 0
-	for i := 0; i <= len(data)-10; i++ {
-		var acc byte
-		for j := 0; j <= 7; j++ {
-			if cnt == 7 {
-				cnt = 0
-				loc++
-			}
-			if loc == 8 {
-				data[ind] = 0
-				ind++
-				loc = 0
-			}
-			v6 := byte(0)
-			if ind < len(data) {
-				v6 = data[ind]
-			}
-			v5 := 1 << loc
-			v4 := int(v6) & v5
-			v3 := v4 >> loc
-			acc ^= byte((v3 << j) & 0xFF)
-			loc++
-			cnt++
-		}
-		data[i] = acc
+	n := len(data) - cryptOverhead
+	if n <= 0 {
+		return
+	}
+	var arr [maxPayloadLength]byte
+	buf := buffer(&arr, pack7.EncodedLen(n))
+	m := copy(buf, data)
+	for i := m; i < len(buf); i++ {
+		buf[i] = 0
+	}
+	pack7.Decode(buf, buf)
+	copy(data, buf[:n])
+	for i := n; i < len(data); i++ {
+		data[i] = 0
 	}
 }
 
 func decryptAndDecode(data []byte) (*GameInfo, error) {
-	data = data[fullHeaderLength:]
-	decrypt(data)
+	_, g, err := decodePayload(data)
+	return g, err
+}
+
+// decodePayload parses the payload header, then decrypts and decodes the game info.
+// The header is returned even if the game info cannot be decoded.
+func decodePayload(data []byte) (*PayloadHeader, *GameInfo, error) {
+	h, n, err := parsePayloadHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	g, err := decodeInfo(data[n:])
+	if err != nil {
+		return h, nil, err
+	}
+	return h, g, nil
+}
+
+// decodeInfo decrypts and decodes the game info that follows the header. The data is not modified.
+func decodeInfo(data []byte) (*GameInfo, error) {
+	var arr [maxPayloadLength]byte
+	g, _, err := decodeInfoTo(buffer(&arr, len(data)), data)
+	return g, err
+}
+
+// decodeInfoTo decrypts the game info into buf, which must be of the same length as data, and decodes it.
+// It returns the decrypted data, excluding the space reserved for encryption.
+func decodeInfoTo(buf, data []byte) (*GameInfo, []byte, error) {
+	if n := len(data) - cryptOverhead; n < infoLength {
+		return nil, nil, fmt.Errorf("%w: payload is too short: %d bytes, expected at least %d",
+			ErrInvalidPayload, len(data), infoLength+cryptOverhead)
+	}
+	copy(buf, data)
+	decrypt(buf)
 
 	var g GameInfo
-	err := g.UnmarshalBinary(data)
+	if err := g.UnmarshalBinary(buf); err != nil {
+		return nil, nil, err
+	}
+	return &g, buf[:len(buf)-cryptOverhead], nil
+}
+
+// payloadMagic is a signature of the game info payload, sent after the pre-header.
+const payloadMagic = "G1P3"
+
+// PayloadHeader is a header of the game info payload, as sent in the room list.
+type PayloadHeader struct {
+	// Prefix is the raw value sent in the pre-header, before the ':', for example "128".
+	// Its meaning is unknown, so it is not validated.
+	Prefix string `json:"prefix"`
+	// Magic is the payload signature. It is always "G1P3".
+	Magic string `json:"magic"`
+	// Version bytes that follow the signature. They may differ between client builds.
+	Version [3]byte `json:"version"`
+}
+
+// ParsePayloadHeader parses the pre-header and validates the header of the game info payload.
+func ParsePayloadHeader(data []byte) (*PayloadHeader, error) {
+	h, _, err := parsePayloadHeader(data)
+	return h, err
+}
+
+// parsePayloadHeader parses the pre-header and the header and returns the offset of encrypted data.
+func parsePayloadHeader(data []byte) (*PayloadHeader, int, error) {
+	i := bytes.IndexByte(data, ':')
+	if i < 0 {
+		return nil, 0, fmt.Errorf("%w: missing pre-header", ErrInvalidPayload)
+	}
+	h, err := parseHeader(data[i+1:])
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	h.Prefix = string(data[:i])
+	return h, i + 1 + headerLength, nil
+}
+
+// parseHeader parses the header that follows the pre-header. Prefix of the returned header is not set.
+func parseHeader(hdr []byte) (*PayloadHeader, error) {
+	if len(hdr) < headerLength {
+		return nil, fmt.Errorf("%w: header is too short: %d bytes", ErrInvalidPayload, len(hdr))
+	}
+	hdr = hdr[:headerLength]
+	if hdr[0] != ':' || string(hdr[1:5]) != payloadMagic {
+		return nil, fmt.Errorf("%w: invalid header: %q", ErrInvalidPayload, hdr)
 	}
-	return &g, nil
+	h := &PayloadHeader{Magic: payloadMagic}
+	copy(h.Version[:], hdr[5:])
+	return h, nil
 }
 
-var header = []byte{':', 'G', '1', 'P', '3', 0x9a, 0x03, 0x01}
+var header = []byte{':', payloadMagic[0], payloadMagic[1], payloadMagic[2], payloadMagic[3], 0x9a, 0x03, 0x01}
 
+// encodeAndEncrypt encodes and encrypts the game info with defaults already set.
+// GameInfo.Unknown must be exactly the space reserved for encryption, otherwise the payload would be truncated.
 func encodeAndEncrypt(g *GameInfo) ([]byte, error) {
+	if len(g.Unknown) != cryptOverhead {
+		return nil, fmt.Errorf("%w: unknown data must be %d bytes, got %d", ErrInvalidPayload, cryptOverhead, len(g.Unknown))
+	}
 	gdata, err := g.MarshalBinary()
 	if err != nil {
 		return nil, err
@@ -95,3 +176,69 @@ func encodeAndEncrypt(g *GameInfo) ([]byte, error) {
 	encryptTo(data[headerLength:], gdata)
 	return data, nil
 }
+
+// DecodePayload decrypts and decodes the game info payload, as sent in the room list.
+// The payload must include the pre-header and the header.
+func DecodePayload(data []byte) (*GameInfo, error) {
+	return decryptAndDecode(data)
+}
+
+// DecodeTopic decrypts and decodes the game info from the topic of the game channel, as set by the host with
+// the TOPIC command. Unlike DecodePayload, the topic has no pre-header, and the leading ':' of the header
+// is stripped, as it is done by the IRC message parser.
+func DecodeTopic(topic []byte) (*GameInfo, error) {
+	data, err := topicInfo(topic)
+	if err != nil {
+		return nil, err
+	}
+	return decodeInfo(data)
+}
+
+// topicInfo validates the header of the topic and returns the encrypted game info that follows it.
+func topicInfo(topic []byte) ([]byte, error) {
+	if len(topic) < headerLength-1 {
+		return nil, fmt.Errorf("%w: header is too short: %d bytes", ErrInvalidPayload, len(topic)+1)
+	}
+	var hdr [headerLength]byte
+	hdr[0] = ':'
+	copy(hdr[1:], topic)
+	if _, err := parseHeader(hdr[:]); err != nil {
+		return nil, err
+	}
+	return topic[headerLength-1:], nil
+}
+
+// DecryptGameInfo decrypts and decodes the game info either from the payload, as sent in the room list
+// (see DecodePayload), or from the topic of the game channel (see DecodeTopic).
+//
+// In addition to the game info, it returns the decrypted binary data, as sent by the game. Unlike
+// GameInfo.MarshalBinary, it preserves the bytes that are lost when decoding, for example after NUL in names.
+// This is useful for inspecting fields with unknown meaning.
+func DecryptGameInfo(data []byte) (*GameInfo, []byte, error) {
+	var (
+		enc []byte
+		err error
+	)
+	if bytes.HasPrefix(data, []byte(payloadMagic)) {
+		enc, err = topicInfo(data)
+	} else {
+		var n int
+		_, n, err = parsePayloadHeader(data)
+		enc = data[n:]
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeInfoTo(make([]byte, len(enc)), enc)
+}
+
+// EncodePayload encodes and encrypts the game info into a payload suitable for the TOPIC command.
+// The payload includes the header, but not the pre-header.
+//
+// Zero values of fields are replaced by the same defaults that are used when hosting the game.
+// The game info itself is not modified.
+func EncodePayload(g *GameInfo) ([]byte, error) {
+	info := *g
+	info.setDefaults()
+	return encodeAndEncrypt(&info)
+}
@@ -0,0 +1,21 @@
+//go:build go1.18
+// +build go1.18
+
+package xwis
+
+import "testing"
+
+func FuzzDecodePayload(f *testing.F) {
+	f.Add([]byte(encodedInfoHdr))
+	f.Add([]byte(encodedInfoHdr[:fullHeaderLength]))
+	f.Add([]byte("128::"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		g, err := DecodePayload(data)
+		if err != nil {
+			return
+		}
+		if _, err = g.MarshalBinary(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
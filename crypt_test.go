@@ -1,12 +1,16 @@
 package xwis
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 const (
+	preHeaderLength  = len("128:")
+	fullHeaderLength = preHeaderLength + headerLength
+
 	encodedInfoHdr = "128::G1P3\x9a\x03\x01\x80\xfe\x83\x80\xd0\xe3\xff\xff\xff\xff\xfbĄ\xadٰ\xe4\u008d\xc3\u058c\x80\xa7\xef\xf0\x8d\xfa֭ۺ\xee\xd2\xd1ˇ\xa4Ѫ\xff\xff\xff\xff\xff\xff\xfb\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\x87¼\x80\x80\x80"
 	decodedInfo    = "\x00\xff\x00\x00\x1d\xff\xff\xff\xff\x9eHheadache\x00NoxCommunity EU\xff\xff\xff\xff\xff\xef\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\a!\x0f\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"
 )
@@ -31,3 +35,120 @@ func TestDecodeEncode(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, encodedInfoHdr[preHeaderLength:], string(data))
 }
+
+func TestDecodeShort(t *testing.T) {
+	for _, n := range []int{0, 4, fullHeaderLength, fullHeaderLength + 10, len(encodedInfoHdr) - 1} {
+		_, err := DecodePayload([]byte(encodedInfoHdr[:n]))
+		require.True(t, errors.Is(err, ErrInvalidPayload), "%d: %v", n, err)
+	}
+	var g GameInfo
+	err := g.UnmarshalBinary([]byte(decodedInfo[:infoLength-1]))
+	require.True(t, errors.Is(err, ErrInvalidPayload), "%v", err)
+}
+
+func TestPayloadHeader(t *testing.T) {
+	h, err := ParsePayloadHeader([]byte(encodedInfoHdr))
+	require.NoError(t, err)
+	require.Equal(t, &PayloadHeader{
+		Prefix:  "128",
+		Magic:   "G1P3",
+		Version: [3]byte{0x9a, 0x03, 0x01},
+	}, h)
+
+	// the prefix is kept as-is, and must not affect decoding
+	for _, pref := range []string{"", "0", "64", "abc"} {
+		data := pref + encodedInfoHdr[3:]
+		h, err = ParsePayloadHeader([]byte(data))
+		require.NoError(t, err, "%q", data)
+		require.Equal(t, pref, h.Prefix)
+		g, err := DecodePayload([]byte(data))
+		require.NoError(t, err, "%q", data)
+		require.Equal(t, "NoxCommunity EU", g.Name)
+	}
+
+	for _, data := range []string{
+		"",
+		encodedInfoHdr[preHeaderLength+1:],
+		"128::G1P4" + encodedInfoHdr[9:],
+		"128::G1P3",
+	} {
+		_, err = ParsePayloadHeader([]byte(data))
+		require.True(t, errors.Is(err, ErrInvalidPayload), "%q: %v", data, err)
+	}
+}
+
+func TestDecodeTopic(t *testing.T) {
+	g, err := DecodeTopic([]byte(encodedInfoHdr[preHeaderLength+1:]))
+	require.NoError(t, err)
+	require.Equal(t, "NoxCommunity EU", g.Name)
+
+	for _, n := range []int{0, 4, headerLength - 1, headerLength + 10} {
+		_, err = DecodeTopic([]byte(encodedInfoHdr[preHeaderLength+1 : preHeaderLength+1+n]))
+		require.True(t, errors.Is(err, ErrInvalidPayload), "%d: %v", n, err)
+	}
+	_, err = DecodeTopic([]byte(encodedInfoHdr[preHeaderLength:]))
+	require.True(t, errors.Is(err, ErrInvalidPayload), "%v", err)
+}
+
+func TestDecryptGameInfo(t *testing.T) {
+	for _, data := range []string{
+		encodedInfoHdr,
+		encodedInfoHdr[preHeaderLength+1:],
+	} {
+		g, raw, err := DecryptGameInfo([]byte(data))
+		require.NoError(t, err)
+		require.Equal(t, "NoxCommunity EU", g.Name)
+		require.Equal(t, decodedInfo[:infoLength], string(raw))
+	}
+	_, _, err := DecryptGameInfo([]byte(encodedInfoHdr[:fullHeaderLength+10]))
+	require.True(t, errors.Is(err, ErrInvalidPayload), "%v", err)
+	_, _, err = DecryptGameInfo([]byte("G1P"))
+	require.True(t, errors.Is(err, ErrInvalidPayload), "%v", err)
+}
+
+func TestEncodePayloadDefaults(t *testing.T) {
+	g := &GameInfo{Name: "test", Map: "estate", MapType: MapTypeArena}
+	data, err := EncodePayload(g)
+	require.NoError(t, err)
+	require.Nil(t, g.Unknown)
+
+	g2, err := DecodeTopic(data[1:])
+	require.NoError(t, err)
+	require.Equal(t, "test", g2.Name)
+	require.Equal(t, defaultFlags, g2.Flags)
+	require.Equal(t, defaultItems, *g2.Items)
+}
+
+func TestEncodePayloadLongUnknown(t *testing.T) {
+	g := &GameInfo{Name: "test", Map: "estate", MapType: MapTypeArena, Unknown: make([]byte, unkLength)}
+	_, err := EncodePayload(g)
+	require.NoError(t, err)
+
+	for _, n := range []int{1, 2, 3, 4, 5, 6, 7, 8, unkLength + 1} {
+		g.Unknown = make([]byte, n)
+		_, err = EncodePayload(g)
+		require.True(t, errors.Is(err, ErrInvalidPayload), "%d: %v", n, err)
+	}
+}
+
+func BenchmarkDecodePayload(b *testing.B) {
+	data := []byte(encodedInfoHdr)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodePayload(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodePayload(b *testing.B) {
+	g, err := DecodePayload([]byte(encodedInfoHdr))
+	require.NoError(b, err)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := EncodePayload(g); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
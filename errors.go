@@ -0,0 +1,91 @@
+package xwis
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/irc.v3"
+)
+
+var (
+	ErrNickInUse     = errors.New("nick is already in use")
+	ErrBadNick       = errors.New("invalid nick")
+	ErrBadPassword   = errors.New("bad password")
+	ErrBanned        = errors.New("banned")
+	ErrNoSuchChannel = errors.New("no such channel")
+	ErrChannelExists = errors.New("channel already exists")
+	ErrChannelFull   = errors.New("channel is full")
+)
+
+// errorCodes maps numeric error replies to errors.
+var errorCodes = map[string]error{
+	"403": ErrNoSuchChannel,
+	"432": ErrBadNick,
+	"433": ErrNickInUse,
+	"437": ErrChannelExists, // TODO: verify that XWIS uses it for JOINGAME
+	"464": ErrBadPassword,
+	"465": ErrBanned,
+	"471": ErrChannelFull,
+	"474": ErrBanned,
+}
+
+// ServerError is a numeric error reply from the server.
+//
+// Known error codes can be checked with errors.Is, for example errors.Is(err, ErrNickInUse).
+type ServerError struct {
+	Code   string
+	Params []string
+}
+
+func (e *ServerError) Error() string {
+	var msg string
+	if len(e.Params) > 1 {
+		// first param is the nick
+		msg = strings.Join(e.Params[1:], " ")
+	}
+	if err := errorCodes[e.Code]; err != nil {
+		return fmt.Sprintf(pkg+": %v (%s): %s", err, e.Code, msg)
+	}
+	return fmt.Sprintf(pkg+": server error %s: %s", e.Code, msg)
+}
+
+func (e *ServerError) Unwrap() error {
+	return errorCodes[e.Code]
+}
+
+func newServerError(m *irc.Message) *ServerError {
+	return &ServerError{
+		Code:   m.Command,
+		Params: append([]string{}, m.Params...),
+	}
+}
+
+// isErrorReply checks if the message is a numeric error reply.
+func isErrorReply(m *irc.Message) bool {
+	if len(m.Command) != 3 {
+		return false
+	}
+	code, err := strconv.Atoi(m.Command)
+	return err == nil && code >= 400 && code < 600
+}
+
+// matchErrors selects numeric error replies related to a given channel.
+// If channel is empty, it selects errors not related to any channel.
+func matchErrors(channel string) Filter {
+	return func(m *irc.Message) bool {
+		if !isErrorReply(m) {
+			return false
+		}
+		if channel != "" {
+			return MatchChannel(channel)(m)
+		}
+		for _, p := range m.Params {
+			if strings.HasPrefix(p, "#") {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -16,10 +16,12 @@ const (
 	unk1Value    = 0xff
 	unk2Value    = 0x489e
 	unkLength    = 9
-	defaultFlags = 8199
+	// defaultFlags is the value this package always sent when hosting a game. Meaning of its bits is not known.
+	defaultFlags = GameFlags(8199)
 )
 
-var unk3Data = [28]byte{
+// defaultItems is the set of items sent by the original Nox client. All items are allowed, except item 44.
+var defaultItems = ItemSet{
 	0xff, 0xff, 0xff, 0xff, 0xff, 0xef, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
 	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
 }
@@ -37,6 +39,9 @@ var (
 
 	_ json.Marshaler   = MapType(0)
 	_ json.Unmarshaler = (*MapType)(nil)
+
+	_ json.Marshaler   = ItemSet{}
+	_ json.Unmarshaler = (*ItemSet)(nil)
 )
 
 const (
@@ -206,8 +211,6 @@ const (
 	MapTypeQuest       = MapType(0x1000)
 )
 
-type GameFlags int
-
 type MapType int
 
 func (m MapType) Unknown() bool {
@@ -283,6 +286,96 @@ func (m *MapType) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// mapTypeMask selects MapType bits from the game flags field.
+const mapTypeMask = 0x1FF0
+
+// GameFlags is a set of game flags, excluding the MapType bits. Both share the same 16 bit field.
+//
+// Meaning of individual bits is not known, so they are not named.
+//
+// TODO: map game options, like weapon restrictions, camper alarm, teams or friendly fire, once their bits are
+// confirmed with the original client. It is not known yet whether they are stored in this field at all.
+type GameFlags int
+
+func (f GameFlags) String() string {
+	return fmt.Sprintf("GameFlags(0x%x)", int(f))
+}
+
+// ItemSet is a bitmap of spells and weapons allowed in the game, as set on the Nox host screen.
+// Each item is identified by its bit index; set bit means the item is allowed. Zero value disallows all items.
+//
+// TODO: map bit indexes to spell and weapon names; the mapping is not verified yet, so only indexes are supported
+type ItemSet [28]byte
+
+// NumItems is the number of items in ItemSet.
+const NumItems = len(ItemSet{}) * 8
+
+// AllItems returns a set with all items allowed.
+func AllItems() ItemSet {
+	var s ItemSet
+	for i := range s {
+		s[i] = 0xff
+	}
+	return s
+}
+
+// Allowed checks if the item with a given index is allowed.
+func (s *ItemSet) Allowed(i int) bool {
+	if i < 0 || i >= NumItems {
+		return false
+	}
+	return s[i/8]&(1<<uint(i%8)) != 0
+}
+
+// Allow allows or disallows the item with a given index.
+func (s *ItemSet) Allow(i int, allow bool) {
+	if i < 0 || i >= NumItems {
+		return
+	}
+	if allow {
+		s[i/8] |= 1 << uint(i%8)
+	} else {
+		s[i/8] &^= 1 << uint(i%8)
+	}
+}
+
+// Disallowed returns indexes of all disallowed items.
+func (s *ItemSet) Disallowed() []int {
+	var out []int
+	for i := 0; i < NumItems; i++ {
+		if !s.Allowed(i) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// MarshalJSON encodes the set as a list of disallowed item indexes.
+func (s ItemSet) MarshalJSON() ([]byte, error) {
+	arr := s.Disallowed()
+	if arr == nil {
+		arr = []int{}
+	}
+	return json.Marshal(arr)
+}
+
+// UnmarshalJSON decodes the set from a list of disallowed item indexes. All other items are allowed.
+func (s *ItemSet) UnmarshalJSON(data []byte) error {
+	var arr []int
+	if err := json.Unmarshal(data, &arr); err != nil {
+		return err
+	}
+	out := AllItems()
+	for _, i := range arr {
+		if i < 0 || i >= NumItems {
+			return fmt.Errorf("unsupported item index: %d", i)
+		}
+		out.Allow(i, false)
+	}
+	*s = out
+	return nil
+}
+
 type GameInfo struct {
 	Addr       string        `json:"addr"`
 	Name       string        `json:"name"`
@@ -301,8 +394,13 @@ type GameInfo struct {
 	TimeLimit  time.Duration `json:"time_limit,omitempty"`
 	Unk1       byte          `json:"-"`
 	Unk2       uint16        `json:"-"`
-	Unk3       [28]byte      `json:"-"`
-	Unknown    []byte        `json:"-"`
+	// Items is a set of allowed spells and weapons. Nil means the default set, used when hosting the game.
+	Items *ItemSet `json:"disallow_items,omitempty"`
+	// Unk3 is the same bitmap as Items. It is set together with Items when decoding.
+	//
+	// Deprecated: use Items. When encoding, Unk3 is only used if Items is nil and Unk3 is not zero.
+	Unk3    [28]byte `json:"-"`
+	Unknown []byte   `json:"-"`
 }
 
 func (g *GameInfo) setDefaults() {
@@ -315,8 +413,12 @@ func (g *GameInfo) setDefaults() {
 	if g.Unk2 == 0 {
 		g.Unk2 = unk2Value
 	}
-	if g.Unk3 == ([28]byte{}) {
-		copy(g.Unk3[:], unk3Data[:])
+	if g.Items == nil {
+		items := defaultItems
+		if g.Unk3 != ([28]byte{}) {
+			items = ItemSet(g.Unk3)
+		}
+		g.Items = &items
 	}
 	if len(g.Unknown) == 0 {
 		g.Unknown = make([]byte, unkLength)
@@ -332,8 +434,118 @@ func (g *GameInfo) setDefaults() {
 	}
 }
 
+const (
+	// infoLength is the length of the binary game info, excluding GameInfo.Unknown.
+	infoLength   = 69
+	maxMapLen    = 9
+	maxNameLen   = 15
+	maxPlayers   = 31
+	maxPing      = math.MaxUint16 - 1
+	maxTimeLimit = math.MaxUint16 * timeLimitRes
+)
+
+// FieldError describes a single invalid field of GameInfo.
+type FieldError struct {
+	// Field is a JSON name of the field.
+	Field string
+	// Reason describes why the value is invalid.
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+// ValidationError lists all invalid fields of GameInfo.
+type ValidationError []*FieldError
+
+func (e ValidationError) Error() string {
+	arr := make([]string, 0, len(e))
+	for _, f := range e {
+		arr = append(arr, f.Error())
+	}
+	return "invalid game info: " + strings.Join(arr, "; ")
+}
+
+// Validate checks that all fields can be encoded without truncation. Zero values that are replaced by defaults
+// when hosting the game are accepted. If some fields are invalid, it returns ValidationError listing all of them.
+func (g *GameInfo) Validate() error {
+	return g.ValidateCodePage(CodePageNone)
+}
+
+// ValidateCodePage is similar to Validate, but checks text fields after converting them to a given code page.
+func (g *GameInfo) ValidateCodePage(cp CodePage) error {
+	var errs ValidationError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, &FieldError{Field: field, Reason: fmt.Sprintf(format, args...)})
+	}
+	checkText := func(field, s string, max int) {
+		if s == "" {
+			add(field, "must not be empty")
+			return
+		}
+		s, err := cp.EncodeString(s)
+		if err != nil {
+			add(field, "%v", err)
+		} else if len(s) > max {
+			add(field, "must be at most %d bytes, got %d", max, len(s))
+		}
+	}
+	checkText("name", g.Name, maxNameLen)
+	checkText("map", g.Map, maxMapLen)
+	if g.MapType&^mapTypeMask != 0 {
+		add("map_type", "invalid value: 0x%x", int(g.MapType))
+	}
+	if g.Flags < 0 || g.Flags > math.MaxUint16 {
+		add("flags", "must fit into 16 bits, got 0x%x", int(g.Flags))
+	} else if g.Flags&mapTypeMask != 0 {
+		add("flags", "must not include map type bits, got 0x%x", int(g.Flags&mapTypeMask))
+	}
+	if g.Access < 0 || g.Access > 0xF {
+		add("access", "invalid value: %d", int(g.Access))
+	}
+	if g.Disallow < 0 || g.Disallow > 0xF {
+		add("disallow", "invalid value: 0x%x", int(g.Disallow))
+	}
+	if g.Resolution < 0 || g.Resolution > 0xF {
+		add("resolution", "invalid value: %d", int(g.Resolution))
+	}
+	if g.MaxPlayers < 0 || g.MaxPlayers > maxPlayers {
+		add("max_players", "must be in range [0, %d], got %d", maxPlayers, g.MaxPlayers)
+	}
+	if g.Players < 0 || g.Players > maxPlayers {
+		add("players", "must be in range [0, %d], got %d", maxPlayers, g.Players)
+	} else if g.MaxPlayers > 0 && g.Players > g.MaxPlayers {
+		add("players", "must not exceed max players (%d), got %d", g.MaxPlayers, g.Players)
+	}
+	if g.MinPing > maxPing {
+		add("min_ping", "must be at most %d, got %d", maxPing, g.MinPing)
+	}
+	if g.MaxPing > maxPing {
+		add("max_ping", "must be at most %d, got %d", maxPing, g.MaxPing)
+	} else if g.MinPing > 0 && g.MaxPing > 0 && g.MinPing > g.MaxPing {
+		add("max_ping", "must not be less than min ping (%d), got %d", g.MinPing, g.MaxPing)
+	}
+	if g.FragLimit < 0 || g.FragLimit > math.MaxUint16 {
+		add("frag_limit", "must be in range [0, %d], got %d", math.MaxUint16, g.FragLimit)
+	}
+	if g.TimeLimit < 0 || g.TimeLimit > maxTimeLimit {
+		add("time_limit", "must be in range [0, %v], got %v", maxTimeLimit, g.TimeLimit)
+	} else if g.TimeLimit%timeLimitRes != 0 {
+		add("time_limit", "must be a multiple of %v, got %v", timeLimitRes, g.TimeLimit)
+	}
+	if n := len(g.Unknown); n != 0 && n != unkLength {
+		// the space is needed for encryption, other lengths corrupt the payload
+		add("unknown", "must be empty or exactly %d bytes, got %d", unkLength, n)
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
 func (g *GameInfo) MarshalBinary() ([]byte, error) {
-	data := make([]byte, 69+len(g.Unknown))
+	data := make([]byte, infoLength+len(g.Unknown))
 	p := data
 
 	// byte 0: access code
@@ -380,15 +592,19 @@ func (g *GameInfo) MarshalBinary() ([]byte, error) {
 	p = p[2:]
 
 	// byte 11-19: map name
-	copy(p[:9], g.Map)
-	p = p[9:]
+	copy(p[:maxMapLen], g.Map)
+	p = p[maxMapLen:]
 
 	// byte 20-34: game name
-	copy(p[:15], g.Name)
-	p = p[15:]
+	copy(p[:maxNameLen], g.Name)
+	p = p[maxNameLen:]
 
-	// byte 35-62: unknown
-	copy(p[:28], g.Unk3[:])
+	// byte 35-62: allowed spells and weapons
+	if g.Items != nil {
+		copy(p[:28], g.Items[:])
+	} else {
+		copy(p[:28], g.Unk3[:])
+	}
 	p = p[28:]
 
 	// byte 63-64: game flags
@@ -410,6 +626,10 @@ func (g *GameInfo) MarshalBinary() ([]byte, error) {
 
 func (g *GameInfo) UnmarshalBinary(data []byte) error {
 	*g = GameInfo{}
+	if len(data) < infoLength {
+		return fmt.Errorf("%w: game info is too short: %d bytes, expected at least %d",
+			ErrInvalidPayload, len(data), infoLength)
+	}
 
 	// byte 0: access code
 	acc := data[0]
@@ -475,16 +695,19 @@ func (g *GameInfo) UnmarshalBinary(data []byte) error {
 	}
 	g.Name = string(gname)
 
-	// byte 35-62: unknown
-	copy(g.Unk3[:], data[:28])
+	// byte 35-62: allowed spells and weapons
+	var items ItemSet
+	copy(items[:], data[:28])
+	g.Items = &items
+	g.Unk3 = items
 	data = data[28:]
 
 	// byte 63-64: game flags
 	g.Flags = GameFlags(endiness.Uint16(data))
 	data = data[2:]
 
-	g.MapType = MapType(g.Flags & 0x1FF0)
-	g.Flags &^= GameFlags(0x1FF0)
+	g.MapType = MapType(g.Flags & mapTypeMask)
+	g.Flags &^= mapTypeMask
 
 	// byte 65-66: frag limit
 	g.FragLimit = int(endiness.Uint16(data))
@@ -1,7 +1,10 @@
 package xwis
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -13,6 +16,7 @@ func TestClassString(t *testing.T) {
 }
 
 func TestGameInfoDecode(t *testing.T) {
+	items := defaultItems
 	var g GameInfo
 	err := g.UnmarshalBinary([]byte(decodedInfo))
 	require.NoError(t, err)
@@ -29,7 +33,8 @@ func TestGameInfoDecode(t *testing.T) {
 		Unk2:       unk2Value,
 		Map:        "headache",
 		Name:       "NoxCommunity EU",
-		Unk3:       unk3Data,
+		Items:      &items,
+		Unk3:       items,
 		Flags:      defaultFlags,
 		MapType:    MapTypeArena,
 		FragLimit:  15,
@@ -37,3 +42,99 @@ func TestGameInfoDecode(t *testing.T) {
 		Unknown:    make([]byte, unkLength),
 	}, g)
 }
+
+func TestItemSetDefault(t *testing.T) {
+	// nil is the default set
+	g := GameInfo{Name: "test", Map: "estate"}
+	data, err := json.Marshal(g)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), `disallow_items`)
+	g.setDefaults()
+	require.Equal(t, defaultItems, *g.Items)
+
+	// all items disallowed is not the same as the default
+	err = json.Unmarshal([]byte(`{"disallow_items":[]}`), &g)
+	require.NoError(t, err)
+	require.Equal(t, AllItems(), *g.Items)
+	var none ItemSet
+	for i := 0; i < NumItems; i++ {
+		none.Allow(i, false)
+	}
+	g = GameInfo{Name: "test", Map: "estate", Items: &none}
+	data, err = json.Marshal(g)
+	require.NoError(t, err)
+	var g2 GameInfo
+	require.NoError(t, json.Unmarshal(data, &g2))
+	require.Equal(t, ItemSet{}, *g2.Items)
+	g2.setDefaults()
+	require.Equal(t, ItemSet{}, *g2.Items)
+
+	bin, err := g2.MarshalBinary()
+	require.NoError(t, err)
+	var g3 GameInfo
+	require.NoError(t, g3.UnmarshalBinary(bin))
+	require.Equal(t, ItemSet{}, *g3.Items)
+
+	// deprecated field is still used if Items is not set
+	g = GameInfo{Name: "test", Map: "estate", Unk3: [28]byte(AllItems())}
+	g.setDefaults()
+	require.Equal(t, AllItems(), *g.Items)
+}
+
+func TestItemSet(t *testing.T) {
+	s := defaultItems
+	require.Equal(t, []int{44}, s.Disallowed())
+	require.True(t, s.Allowed(0))
+	require.False(t, s.Allowed(44))
+	require.False(t, s.Allowed(NumItems))
+
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	require.Equal(t, `[44]`, string(data))
+
+	var s2 ItemSet
+	err = json.Unmarshal(data, &s2)
+	require.NoError(t, err)
+	require.Equal(t, defaultItems, s2)
+
+	s2.Allow(44, true)
+	s2.Allow(3, false)
+	require.Equal(t, []int{3}, s2.Disallowed())
+
+	err = json.Unmarshal([]byte(`[224]`), &s2)
+	require.Error(t, err)
+}
+
+func TestGameInfoValidate(t *testing.T) {
+	g := GameInfo{
+		Name:       "NoxCommunity EU",
+		Map:        "headache",
+		MapType:    MapTypeArena,
+		MaxPlayers: 31,
+		FragLimit:  15,
+	}
+	require.NoError(t, g.Validate())
+	g.Unknown = make([]byte, unkLength)
+	require.NoError(t, g.Validate())
+
+	g = GameInfo{
+		Name:       "NoxCommunity EU 2",
+		Map:        "headache2",
+		MapType:    MapTypeArena,
+		Flags:      GameFlags(MapTypeCTF),
+		Players:    5,
+		MaxPlayers: 4,
+		FragLimit:  70000,
+		TimeLimit:  time.Second / 2,
+		Unknown:    make([]byte, unkLength-1),
+	}
+	err := g.Validate()
+	require.Error(t, err)
+	var verr ValidationError
+	require.True(t, errors.As(err, &verr))
+	var fields []string
+	for _, f := range verr {
+		fields = append(fields, f.Field)
+	}
+	require.Equal(t, []string{"name", "flags", "players", "frag_limit", "time_limit", "unknown"}, fields)
+}
@@ -1,9 +1,18 @@
 module github.com/noxworld-dev/xwis
 
-go 1.13
+go 1.17
 
 require (
 	github.com/spf13/cobra v1.0.0
 	github.com/stretchr/testify v1.4.0
+	golang.org/x/text v0.13.0
 	gopkg.in/irc.v3 v3.1.3
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/spf13/pflag v1.0.3 // indirect
+	gopkg.in/yaml.v2 v2.2.8 // indirect
+)
@@ -0,0 +1,165 @@
+package xwis
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+)
+
+// DialFunc is a function used to establish connections to the lobby server.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Option configures the Client.
+type Option func(o *options)
+
+type options struct {
+	prod             *Product
+	dial             DialFunc
+	timeout          time.Duration
+	handshakeTimeout time.Duration
+	log              *log.Logger
+	randomLogin      func() string
+	reconn           *ReconnectConfig
+	keepAlive        time.Duration
+	keepAliveTimeout time.Duration
+	strictGameInfo   bool
+	codePage         CodePage
+	wire             WireHook
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		prod:             ProductNox,
+		dial:             dialer.DialContext,
+		timeout:          defaultTimeout,
+		handshakeTimeout: defaultTimeout,
+		randomLogin:      randomLogin,
+	}
+	for _, fnc := range opts {
+		fnc(o)
+	}
+	return o
+}
+
+// logger returns the logger for debug messages, or nil if logging is disabled.
+func (o *options) logger() *log.Logger {
+	if o.log != nil {
+		return o.log
+	}
+	return DebugLog
+}
+
+// WithProduct sets the product profile used by the client. Default is ProductNox.
+func WithProduct(prod *Product) Option {
+	return func(o *options) {
+		if prod != nil {
+			o.prod = prod
+		}
+	}
+}
+
+// WithDialer sets a custom dialer for connections to the lobby server.
+func WithDialer(d *net.Dialer) Option {
+	return func(o *options) {
+		if d != nil {
+			o.dial = d.DialContext
+		}
+	}
+}
+
+// WithDialContext sets a custom dial function for connections to the lobby server.
+// It can be used to connect via a proxy.
+func WithDialContext(dial DialFunc) Option {
+	return func(o *options) {
+		if dial != nil {
+			o.dial = dial
+		}
+	}
+}
+
+// WithTimeout sets the timeout for client operations, used if the context has no deadline. Default is 30 seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.timeout = d
+		}
+	}
+}
+
+// WithHandshakeTimeout sets the timeout for the login, used if the context has no deadline. Default is 30 seconds.
+func WithHandshakeTimeout(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.handshakeTimeout = d
+		}
+	}
+}
+
+// WithLogger sets a logger for debug messages of the client. By default, DebugLog is used.
+// Passing nil restores the default. To disable logging while DebugLog is set, pass a logger that writes
+// to ioutil.Discard.
+func WithLogger(l *log.Logger) Option {
+	return func(o *options) {
+		o.log = l
+	}
+}
+
+// WithLoginGenerator sets a function that generates a random login, if none was provided.
+func WithLoginGenerator(fnc func() string) Option {
+	return func(o *options) {
+		if fnc != nil {
+			o.randomLogin = fnc
+		}
+	}
+}
+
+// WithReconnect enables automatic reconnects. See ReconnectConfig for details.
+func WithReconnect(conf ReconnectConfig) Option {
+	conf.setDefaults()
+	return func(o *options) {
+		o.reconn = &conf
+	}
+}
+
+// WithKeepAlive enables client-side keepalive. See Client.KeepAlive for details.
+func WithKeepAlive(interval, timeout time.Duration) Option {
+	return func(o *options) {
+		o.keepAlive = interval
+		o.keepAliveTimeout = timeout
+	}
+}
+
+// WithStrictGameInfo makes RegisterGame, HostGame and Game.Update validate the game info and fail with
+// ValidationError, instead of silently truncating fields that do not fit into the payload.
+func WithStrictGameInfo() Option {
+	return func(o *options) {
+		o.strictGameInfo = true
+	}
+}
+
+// WithCodePage sets the code page used for game names, map names, channel names and chat. The client requests it
+// from the server with SETCODEPAGE. By default, text is sent as-is and the code page is not negotiated.
+func WithCodePage(cp CodePage) Option {
+	return func(o *options) {
+		o.codePage = cp
+	}
+}
+
+// WithWireHook sets a hook that observes all raw protocol lines sent and received by the client.
+// If the option is used multiple times, all hooks are called in order.
+func WithWireHook(h WireHook) Option {
+	return func(o *options) {
+		if h == nil {
+			return
+		}
+		if prev := o.wire; prev != nil {
+			o.wire = func(dir Direction, line string) {
+				prev(dir, line)
+				h(dir, line)
+			}
+		} else {
+			o.wire = h
+		}
+	}
+}
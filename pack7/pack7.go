@@ -0,0 +1,200 @@
+// Package pack7 implements the 8-to-7-bit packing used by Westwood Online to send binary payloads as text,
+// for example the game info in the topic of the game channel.
+//
+// The input is treated as a stream of bits, starting from the least significant bit of the first byte.
+// Each encoded byte carries the next 7 bits of the stream in its low bits, and has the high bit always set,
+// so the encoded data never contains NUL, CR, LF, spaces or other characters special for IRC.
+// The last group of bits is padded with zeros, thus the encoded data is 8/7 times larger, rounded up.
+//
+// When decoding, the high bit of each byte is ignored, and trailing bits that do not form a full byte are discarded.
+// This makes Decode an exact inverse of Encode for inputs of any length.
+package pack7
+
+import "io"
+
+const highBit = 0x80
+
+// EncodedLen returns the length of encoding of n source bytes.
+func EncodedLen(n int) int {
+	return (n*8 + 6) / 7
+}
+
+// DecodedLen returns the length of decoding of n source bytes.
+func DecodedLen(n int) int {
+	return n * 7 / 8
+}
+
+// Encode encodes src into EncodedLen(len(src)) bytes of dst and returns the number of bytes written.
+// Encode panics if dst is too short. The buffers must not overlap.
+func Encode(dst, src []byte) int {
+	_ = dst[:EncodedLen(len(src))]
+	var s state
+	_, j := s.encode(dst, src)
+	return j + s.flush(dst[j:])
+}
+
+// AppendEncode appends encoded src to dst and returns the extended buffer.
+func AppendEncode(dst, src []byte) []byte {
+	n := len(dst)
+	dst = grow(dst, EncodedLen(len(src)))
+	Encode(dst[n:], src)
+	return dst
+}
+
+// Decode decodes src into DecodedLen(len(src)) bytes of dst and returns the number of bytes written.
+// Decode panics if dst is too short. Decoding can be done in place, with dst and src pointing to the same buffer.
+func Decode(dst, src []byte) int {
+	_ = dst[:DecodedLen(len(src))]
+	var s state
+	return s.decode(dst, src)
+}
+
+// AppendDecode appends decoded src to dst and returns the extended buffer.
+func AppendDecode(dst, src []byte) []byte {
+	n := len(dst)
+	dst = grow(dst, DecodedLen(len(src)))
+	Decode(dst[n:], src)
+	return dst
+}
+
+// grow extends the buffer by n bytes.
+func grow(b []byte, n int) []byte {
+	if l := len(b) + n; l <= cap(b) {
+		return b[:l]
+	}
+	out := make([]byte, len(b)+n)
+	copy(out, b)
+	return out
+}
+
+// state holds bits that were not converted yet.
+type state struct {
+	acc uint
+	n   uint
+}
+
+// encode encodes as much of src as fits into dst. It returns the number of bytes consumed and written.
+// Bits that do not form a full group are kept in the state.
+func (s *state) encode(dst, src []byte) (int, int) {
+	i, j := 0, 0
+	for ; i < len(src); i++ {
+		if j+int(s.n+8)/7 > len(dst) {
+			break
+		}
+		s.acc |= uint(src[i]) << s.n
+		s.n += 8
+		for s.n >= 7 {
+			dst[j] = byte(s.acc&0x7f) | highBit
+			j++
+			s.acc >>= 7
+			s.n -= 7
+		}
+	}
+	return i, j
+}
+
+// flush writes the last partial group, if any, and returns the number of bytes written.
+func (s *state) flush(dst []byte) int {
+	if s.n == 0 {
+		return 0
+	}
+	dst[0] = byte(s.acc&0x7f) | highBit
+	s.acc, s.n = 0, 0
+	return 1
+}
+
+// decode decodes src into dst, which must be large enough. It returns the number of bytes written.
+// Bits that do not form a full byte are kept in the state.
+func (s *state) decode(dst, src []byte) int {
+	j := 0
+	for _, b := range src {
+		s.acc |= uint(b&0x7f) << s.n
+		s.n += 7
+		if s.n >= 8 {
+			dst[j] = byte(s.acc)
+			j++
+			s.acc >>= 8
+			s.n -= 8
+		}
+	}
+	return j
+}
+
+// bufSize is the size of internal buffers of the encoder and decoder.
+const bufSize = 4096
+
+type encoder struct {
+	w   io.Writer
+	err error
+	st  state
+	buf [bufSize]byte
+}
+
+// NewEncoder returns a stream encoder. Data written to it is encoded and written to w.
+// The caller must Close the encoder to flush the last partial group of bits. Closing does not close w.
+func NewEncoder(w io.Writer) io.WriteCloser {
+	return &encoder{w: w}
+}
+
+func (e *encoder) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	total := 0
+	for len(p) > 0 {
+		i, j := e.st.encode(e.buf[:], p)
+		if j != 0 {
+			if _, err := e.w.Write(e.buf[:j]); err != nil {
+				e.err = err
+				return total, err
+			}
+		}
+		p = p[i:]
+		total += i
+	}
+	return total, nil
+}
+
+// Close flushes the last partial group of bits, if any.
+func (e *encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if j := e.st.flush(e.buf[:]); j != 0 {
+		if _, err := e.w.Write(e.buf[:j]); err != nil {
+			e.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+type decoder struct {
+	r   io.Reader
+	err error
+	st  state
+	buf [bufSize]byte
+	out []byte // decoded bytes that were not read yet
+}
+
+// NewDecoder returns a stream decoder that reads and decodes data from r.
+// Trailing bits that do not form a full byte are discarded when r returns io.EOF.
+func NewDecoder(r io.Reader) io.Reader {
+	return &decoder{r: r}
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		n, err := d.r.Read(d.buf[:])
+		d.err = err
+		// decoded data is always shorter, so it can be done in place
+		j := d.st.decode(d.buf[:], d.buf[:n])
+		d.out = d.buf[:j]
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
@@ -0,0 +1,177 @@
+package pack7
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// game info payload, without the header
+	gameInfoEncoded = "\x80\xfe\x83\x80\xd0\xe3\xff\xff\xff\xff\xfbĄ\xadٰ\xe4\u008d\xc3֌\x80\xa7\xef\xf0\x8d\xfa֭ۺ\xee\xd2\xd1ˇ\xa4Ѫ\xff\xff\xff\xff\xff\xff\xfb\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\x87¼\x80\x80\x80"
+	gameInfoDecoded = "\x00\xff\x00\x00\x1d\xff\xff\xff\xff\x9eHheadache\x00NoxCommunity EU\xff\xff\xff\xff\xff\xef\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\a!\x0f\x00\x00\x00"
+)
+
+func TestVectors(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		dec  string
+		enc  string
+	}{
+		{name: "empty", dec: "", enc: ""},
+		{name: "zero", dec: "\x00", enc: "\x80\x80"},
+		{name: "one", dec: "\x01", enc: "\x81\x80"},
+		{name: "ff", dec: "\xff", enc: "\xff\x81"},
+		{name: "seven", dec: "\xff\xff\xff\xff\xff\xff\xff", enc: "\xff\xff\xff\xff\xff\xff\xff\xff"},
+		// the game drops the last partial group, so the last byte is not compared
+		{name: "game info", dec: gameInfoDecoded, enc: gameInfoEncoded + "\x80"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			enc := make([]byte, EncodedLen(len(c.dec)))
+			n := Encode(enc, []byte(c.dec))
+			require.Equal(t, len(enc), n)
+			require.Equal(t, c.enc, string(enc))
+
+			dec := make([]byte, DecodedLen(len(c.enc)))
+			n = Decode(dec, []byte(c.enc))
+			require.Equal(t, len(dec), n)
+			require.Equal(t, c.dec, string(dec))
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for n := 0; n <= 100; n++ {
+		src := make([]byte, n)
+		rnd.Read(src)
+		enc := AppendEncode(nil, src)
+		require.Len(t, enc, EncodedLen(n))
+		for _, b := range enc {
+			require.True(t, b&highBit != 0, "%x", enc)
+		}
+		require.Equal(t, n, DecodedLen(len(enc)))
+		require.Equal(t, string(src), string(AppendDecode(nil, enc)))
+
+		// in place
+		Decode(enc, enc)
+		require.Equal(t, string(src), string(enc[:n]))
+	}
+}
+
+func TestDecodeHighBit(t *testing.T) {
+	enc := AppendEncode(nil, []byte(gameInfoDecoded))
+	for i := range enc {
+		enc[i] &^= highBit
+	}
+	require.Equal(t, gameInfoDecoded, string(AppendDecode(nil, enc)))
+}
+
+func TestAppend(t *testing.T) {
+	buf := make([]byte, 2, 16)
+	buf = AppendEncode(buf, []byte("\xff"))
+	require.Equal(t, "\x00\x00\xff\x81", string(buf))
+	buf = AppendDecode(buf[:2], []byte("\xff\x81"))
+	require.Equal(t, "\x00\x00\xff", string(buf))
+}
+
+func TestStream(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 7, 8, 100, bufSize - 1, bufSize, 3*bufSize + 5} {
+		src := make([]byte, n)
+		rnd.Read(src)
+		exp := AppendEncode(nil, src)
+
+		var buf bytes.Buffer
+		w := NewEncoder(&buf)
+		for p := src; len(p) > 0; {
+			k := 1 + rnd.Intn(2*bufSize)
+			if k > len(p) {
+				k = len(p)
+			}
+			m, err := w.Write(p[:k])
+			require.NoError(t, err)
+			require.Equal(t, k, m)
+			p = p[k:]
+		}
+		require.NoError(t, w.Close())
+		require.Equal(t, exp, buf.Bytes(), "%d", n)
+
+		dec, err := ioutil.ReadAll(NewDecoder(bytes.NewReader(exp)))
+		require.NoError(t, err)
+		require.Equal(t, src, dec, "%d", n)
+
+		dec, err = ioutil.ReadAll(NewDecoder(iotest.OneByteReader(bytes.NewReader(exp))))
+		require.NoError(t, err)
+		require.Equal(t, src, dec, "%d", n)
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	w := NewEncoder(errWriter{})
+	_, err := w.Write([]byte("abc"))
+	require.Error(t, err)
+	require.Error(t, w.Close())
+
+	_, err = ioutil.ReadAll(NewDecoder(iotest.TimeoutReader(bytes.NewReader([]byte(gameInfoEncoded)))))
+	require.Equal(t, iotest.ErrTimeout, err)
+}
+
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, iotest.ErrTimeout
+}
+
+func BenchmarkEncode(b *testing.B) {
+	src := []byte(gameInfoDecoded)
+	dst := make([]byte, EncodedLen(len(src)))
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		Encode(dst, src)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	src := []byte(gameInfoEncoded)
+	dst := make([]byte, DecodedLen(len(src)))
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		Decode(dst, src)
+	}
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	src := make([]byte, 1<<16)
+	rand.New(rand.NewSource(1)).Read(src)
+	w := NewEncoder(ioutil.Discard)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		if _, err := w.Write(src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	src := AppendEncode(nil, make([]byte, 1<<16))
+	buf := make([]byte, 4096)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		r := NewDecoder(bytes.NewReader(src))
+		for {
+			_, err := r.Read(buf)
+			if err != nil {
+				break
+			}
+		}
+	}
+}
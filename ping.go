@@ -0,0 +1,82 @@
+package xwis
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"gopkg.in/irc.v3"
+)
+
+func (c *Client) writePong(m *irc.Message) {
+	_ = c.writeReq(context.Background(), func(w *writer) error {
+		return w.WriteLine((&irc.Message{Command: "PONG", Params: m.Params}).String())
+	})
+}
+
+// failConn closes the connection with a given error, unless it was already replaced.
+func (c *Client) failConn(conn net.Conn, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.c != conn {
+		return
+	}
+	c.ferr = err
+	_ = conn.Close()
+}
+
+// KeepAlive starts sending PING to the server every interval. If the server does not reply in a given timeout,
+// the connection is considered dead and is closed with ErrPingTimeout. In the reconnecting mode the client will
+// then reconnect, otherwise it stops and all pending operations fail.
+//
+// Keepalive stops when the client is closed.
+func (c *Client) KeepAlive(interval, timeout time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	if timeout <= 0 {
+		timeout = interval
+	}
+	go c.keepAliveLoop(interval, timeout)
+}
+
+func (c *Client) keepAliveLoop(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case <-c.stop:
+			return
+		case <-c.dead:
+			return
+		case <-ticker.C:
+		}
+		c.ping(strconv.Itoa(i), timeout)
+	}
+}
+
+func (c *Client) ping(token string, timeout time.Duration) {
+	read := c.request(MatchAll(MatchCommands("PONG"), func(m *irc.Message) bool {
+		return m.Trailing() == token
+	}), 1)
+	defer read.Close()
+	c.mu.Lock()
+	conn := c.c
+	_ = c.c.SetWriteDeadline(time.Now().Add(timeout))
+	err := c.w.WriteLinef("PING :%s", token)
+	if err == nil {
+		err = c.w.Flush()
+	}
+	_ = c.c.SetWriteDeadline(time.Time{})
+	c.mu.Unlock()
+	if err != nil {
+		// connection is already broken; the read loop will handle it
+		return
+	}
+	ctx, cancel := c.stopContext(timeout)
+	defer cancel()
+	if _, err := read.Next(ctx); err == context.DeadlineExceeded {
+		c.failConn(conn, ErrPingTimeout)
+	}
+}
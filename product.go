@@ -0,0 +1,82 @@
+package xwis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// wolSKU is the SKU of the WOL API itself.
+	wolSKU = 32512
+)
+
+// ProductNox is the product profile for Nox.
+var ProductNox = &Product{
+	Name:          "Nox",
+	GameID:        37,
+	SKU:           9472,
+	ClientVersion: 11015,
+	Version:       65540,
+	APIVersion:    65551,
+	Serial:        "2227973051451322323085",
+	Lobbies: []string{
+		"Brin",
+		"Ix",
+		"Dun Mir",
+	},
+}
+
+// Product is a profile of the game client that connects to XWIS.
+//
+// Only Nox profile is provided by this package, but other titles can be supported by defining a custom profile.
+type Product struct {
+	// Name of the game.
+	Name string
+	// GameID is the game identifier on XWIS. It is used in room names and in room list requests.
+	GameID int
+	// SKU of the game client. For most games it equals to GameID << 8.
+	SKU int
+	// ClientVersion is sent in CVERS command on login.
+	ClientVersion int
+	// Version of the game client, used when discovering lobby servers.
+	Version int
+	// APIVersion is the version of WOL API used by the game client.
+	APIVersion int
+	// Serial is the game serial number, used when discovering lobby servers.
+	Serial string
+	// Lobbies are names of lobby chat rooms, in the order of their index.
+	Lobbies []string
+}
+
+func (p *Product) lobbyPrefix() string {
+	return fmt.Sprintf("Lob_%d_", p.GameID)
+}
+
+// lobbyIndex returns an index of the lobby chat room of this product. Name must not include the '#' prefix.
+func (p *Product) lobbyIndex(name string) (int, bool) {
+	pref := p.lobbyPrefix()
+	if !strings.HasPrefix(name, pref) {
+		return 0, false
+	}
+	ind, err := strconv.ParseUint(name[len(pref):], 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return int(ind), true
+}
+
+// lobbyName returns a human-readable name of the lobby chat room. Name must not include the '#' prefix.
+//
+// Lobbies that are not listed in the product profile are named by their index, starting from 1, e.g. "Lobby 4".
+// Names of other chat rooms are returned as-is.
+func (p *Product) lobbyName(name string) string {
+	ind, ok := p.lobbyIndex(name)
+	if !ok {
+		return name
+	}
+	if ind < len(p.Lobbies) {
+		return p.Lobbies[ind]
+	}
+	return "Lobby " + strconv.Itoa(ind+1)
+}
@@ -14,16 +14,22 @@ import (
 var DebugLog *log.Logger
 
 type reader struct {
-	sc *bufio.Scanner
+	sc   *bufio.Scanner
+	log  *log.Logger
+	hook WireHook
 }
 
-func newReader(r io.Reader) *reader {
-	return &reader{sc: bufio.NewScanner(r)}
+func newReader(r io.Reader, log *log.Logger, hook WireHook) *reader {
+	return &reader{sc: bufio.NewScanner(r), log: log, hook: hook}
 }
 
 func (r *reader) ReadLine() (string, error) {
 	if r.sc.Scan() {
-		return r.sc.Text(), nil
+		line := r.sc.Text()
+		if r.hook != nil {
+			r.hook(Received, line)
+		}
+		return line, nil
 	}
 	if err := r.sc.Err(); err != nil {
 		return "", err
@@ -59,8 +65,11 @@ func (r *reader) WaitFor(ctx context.Context, cmds ...string) (*irc.Message, err
 				return m, nil
 			}
 		}
-		if DebugLog != nil {
-			DebugLog.Println(m)
+		if isErrorReply(m) {
+			return nil, newServerError(m)
+		}
+		if r.log != nil {
+			r.log.Println(m)
 		}
 	}
 }
@@ -0,0 +1,158 @@
+package xwis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultReconnectMinDelay = time.Second
+	defaultReconnectMaxDelay = time.Minute
+)
+
+// ReconnectConfig controls automatic reconnects of the Client.
+type ReconnectConfig struct {
+	// MinDelay is the delay before the first reconnect attempt. Default is 1 second.
+	MinDelay time.Duration
+	// MaxDelay limits the exponential backoff between attempts. Default is 1 minute.
+	MaxDelay time.Duration
+	// MaxAttempts limits the number of consecutive reconnect attempts. Zero means no limit.
+	MaxAttempts int
+	// OnEvent is called for each reconnect event, if set. It must not block, since it may delay reconnects.
+	OnEvent func(ev ReconnectEvent)
+}
+
+// ReconnectEvent describes a state change of the reconnecting Client.
+type ReconnectEvent struct {
+	// Attempt is the number of the reconnect attempt. It is zero for the event reporting the lost connection.
+	Attempt int
+	// Connected is set when the client is logged in again, and all games and channels were restored.
+	Connected bool
+	// Err is the reason of the lost connection, the error of the reconnect attempt,
+	// or the first error that occurred while restoring games and channels.
+	Err error
+}
+
+// NewReconnectingClient is similar to NewClientWithAddress, but the returned client automatically reconnects
+// when the connection is lost. After reconnecting, it joins all chat channels again and re-registers all live games
+// using the last known GameInfo.
+//
+// It is a shorthand for NewClientWithAddress with WithReconnect option.
+func NewReconnectingClient(ctx context.Context, addr, login, pass string, conf ReconnectConfig, opts ...Option) (*Client, error) {
+	return NewClientWithAddress(ctx, addr, login, pass, append(opts, WithReconnect(conf))...)
+}
+
+func (conf *ReconnectConfig) setDefaults() {
+	if conf.MinDelay <= 0 {
+		conf.MinDelay = defaultReconnectMinDelay
+	}
+	if conf.MaxDelay < conf.MinDelay {
+		conf.MaxDelay = defaultReconnectMaxDelay
+		if conf.MaxDelay < conf.MinDelay {
+			conf.MaxDelay = conf.MinDelay
+		}
+	}
+}
+
+func (c *Client) reconnectEvent(ev ReconnectEvent) {
+	if log := c.log(); log != nil {
+		log.Printf("reconnect: attempt=%d connected=%v err=%v", ev.Attempt, ev.Connected, ev.Err)
+	}
+	if c.reconn.OnEvent != nil {
+		c.reconn.OnEvent(ev)
+	}
+}
+
+// stopContext returns a context with a given timeout that is also cancelled when the client is closed.
+func (c *Client) stopContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// reconnect tries to establish a new connection after the current one fails.
+// It returns an error only if the client should stop.
+func (c *Client) reconnect(cause error) error {
+	c.stopRequests(cause)
+	c.reconnectEvent(ReconnectEvent{Err: cause})
+	delay := c.reconn.MinDelay
+	for attempt := 1; c.reconn.MaxAttempts <= 0 || attempt <= c.reconn.MaxAttempts; attempt++ {
+		t := time.NewTimer(delay)
+		select {
+		case <-c.stop:
+			t.Stop()
+			return ErrClientClosed
+		case <-t.C:
+		}
+		ctx, cancel := c.stopContext(c.opts.timeout)
+		err := c.connect(ctx)
+		cancel()
+		select {
+		case <-c.stop:
+			return ErrClientClosed
+		default:
+		}
+		if err == nil {
+			c.updateStats(func(s *Stats) {
+				s.Reconnects++
+			})
+			go c.restore(attempt)
+			return nil
+		}
+		c.reconnectEvent(ReconnectEvent{Attempt: attempt, Err: err})
+		if delay *= 2; delay > c.reconn.MaxDelay {
+			delay = c.reconn.MaxDelay
+		}
+	}
+	return fmt.Errorf(pkg+": reconnect failed: %w", cause)
+}
+
+// restore joins all chat channels and re-registers all games after reconnecting.
+func (c *Client) restore(attempt int) {
+	c.mu.Lock()
+	channels := make([]string, 0, len(c.channels))
+	for name := range c.channels {
+		channels = append(channels, name)
+	}
+	games := make([]*Game, 0, len(c.games))
+	for g := range c.games {
+		games = append(games, g)
+	}
+	c.mu.Unlock()
+
+	ctx, cancel := c.stopContext(c.opts.timeout)
+	defer cancel()
+
+	var first error
+	for _, name := range channels {
+		read, err := c.writeJoinReq(ctx, name)
+		if err == nil {
+			_, err = read.WaitFor(ctx, "366")
+			_ = read.Close()
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	for _, g := range games {
+		c.mu.Lock()
+		info := g.info
+		c.mu.Unlock()
+		if _, err := c.writeHostGameReq(ctx, &info); err != nil && first == nil {
+			first = err
+		}
+	}
+	select {
+	case <-c.stop:
+		return
+	default:
+	}
+	c.reconnectEvent(ReconnectEvent{Attempt: attempt, Connected: true, Err: first})
+}
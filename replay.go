@@ -0,0 +1,108 @@
+package xwis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// ErrReplayMismatch is returned when the client sends a line that differs from the transcript.
+var ErrReplayMismatch = errors.New("line does not match the transcript")
+
+// Replayer plays a recorded transcript back to the client, acting as a server.
+//
+// Lines sent by the client are compared with the transcript, and received lines are sent back in the recorded order,
+// as soon as all preceding sent lines are matched. Timing of the transcript is not preserved. After the end of the
+// transcript, all lines sent by the client are discarded.
+//
+// Replayer serves a single connection. Use Dial with WithDialContext to connect the Client to it.
+type Replayer struct {
+	entries []WireEntry
+	done    chan struct{}
+
+	mu     sync.Mutex
+	dialed bool
+	err    error
+}
+
+// NewReplayer creates a replayer for transcript entries, as returned by ReadTranscript.
+func NewReplayer(entries []WireEntry) *Replayer {
+	return &Replayer{entries: entries, done: make(chan struct{})}
+}
+
+// Dial returns a connection to the replayed server. It can only be called once.
+func (r *Replayer) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dialed {
+		return nil, errors.New(pkg + ": replayer: transcript is already in use")
+	}
+	r.dialed = true
+	cli, srv := net.Pipe()
+	go r.serve(srv)
+	return cli, nil
+}
+
+// Done returns a channel that is closed when the client disconnects.
+func (r *Replayer) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err returns the first mismatch between the client and the transcript, or an error that interrupted the replay.
+func (r *Replayer) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Replayer) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *Replayer) serve(conn net.Conn) {
+	defer close(r.done)
+	defer conn.Close()
+	sc := bufio.NewScanner(conn)
+	bw := bufio.NewWriter(conn)
+	for i, e := range r.entries {
+		switch e.Dir {
+		case Received:
+			if _, err := bw.WriteString(e.Line + eol); err != nil {
+				r.setErr(fmt.Errorf(pkg+": replayer: entry %d: %w", i+1, err))
+				return
+			}
+		case Sent:
+			if err := bw.Flush(); err != nil {
+				r.setErr(fmt.Errorf(pkg+": replayer: entry %d: %w", i+1, err))
+				return
+			}
+			if !sc.Scan() {
+				err := sc.Err()
+				if err == nil {
+					err = io.ErrUnexpectedEOF
+				}
+				r.setErr(fmt.Errorf(pkg+": replayer: entry %d: %w", i+1, err))
+				return
+			}
+			if line := sc.Text(); line != e.Line {
+				r.setErr(fmt.Errorf(pkg+": replayer: entry %d: %w: expected %q, got %q", i+1, ErrReplayMismatch, e.Line, line))
+				return
+			}
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		r.setErr(fmt.Errorf(pkg+": replayer: %w", err))
+		return
+	}
+	for sc.Scan() {
+		// discard lines until the client disconnects
+	}
+}
@@ -0,0 +1,30 @@
+package xwis
+
+import "time"
+
+// Stats contains counters collected by the Client since it was created.
+type Stats struct {
+	// Reconnects is the number of successful reconnects.
+	Reconnects uint64
+	// ListRequests is the number of successful room list requests.
+	ListRequests uint64
+	// ListErrors is the number of failed room list requests.
+	ListErrors uint64
+	// ListDuration is the total time spent in successful room list requests.
+	ListDuration time.Duration
+	// DecodeFailures is the number of game payloads in the room list that could not be decoded.
+	DecodeFailures uint64
+}
+
+// Stats returns a snapshot of client counters.
+func (c *Client) Stats() Stats {
+	c.stmu.Lock()
+	defer c.stmu.Unlock()
+	return c.stats
+}
+
+func (c *Client) updateStats(fnc func(s *Stats)) {
+	c.stmu.Lock()
+	fnc(&c.stats)
+	c.stmu.Unlock()
+}
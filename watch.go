@@ -0,0 +1,162 @@
+package xwis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	RoomAdded   = RoomEventType(1)
+	RoomRemoved = RoomEventType(2)
+	RoomChanged = RoomEventType(3)
+)
+
+// RoomEventType is a type of the room change.
+type RoomEventType int
+
+func (t RoomEventType) String() string {
+	switch t {
+	case RoomAdded:
+		return "added"
+	case RoomRemoved:
+		return "removed"
+	case RoomChanged:
+		return "changed"
+	}
+	return fmt.Sprintf("RoomEventType(%d)", int(t))
+}
+
+// RoomEvent describes a change in the room list.
+type RoomEvent struct {
+	Type RoomEventType
+	// Old state of the room. It is nil for RoomAdded.
+	Old *Room
+	// New state of the room. It is nil for RoomRemoved.
+	New *Room
+}
+
+// Room returns the latest known state of the room.
+func (e RoomEvent) Room() *Room {
+	if e.New != nil {
+		return e.New
+	}
+	return e.Old
+}
+
+// RoomWatcher polls the room list and reports changes. See Client.WatchRooms.
+type RoomWatcher struct {
+	events chan RoomEvent
+	done   chan struct{}
+	err    error
+}
+
+// Events returns a channel with room events. The channel is closed when the watcher stops.
+func (w *RoomWatcher) Events() <-chan RoomEvent {
+	return w.events
+}
+
+// Done returns a channel that is closed when the watcher stops.
+func (w *RoomWatcher) Done() <-chan struct{} {
+	return w.done
+}
+
+// Err returns the reason why the watcher stopped, or nil if it is still running.
+func (w *RoomWatcher) Err() error {
+	select {
+	case <-w.done:
+		return w.err
+	default:
+		return nil
+	}
+}
+
+// WatchRooms polls the room list with a given interval and reports changes as events.
+//
+// The first poll reports all existing rooms as RoomAdded. Games are reported as changed when any field
+// of the GameInfo changes, while chat rooms are reported as changed when the number of users changes.
+//
+// Watcher stops when the context is cancelled or when listing rooms fails. The interval must be positive.
+func (c *Client) WatchRooms(ctx context.Context, interval time.Duration) (*RoomWatcher, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf(pkg+": invalid watch interval: %v", interval)
+	}
+	w := &RoomWatcher{
+		events: make(chan RoomEvent, 16),
+		done:   make(chan struct{}),
+	}
+	go w.run(ctx, c, interval)
+	return w, nil
+}
+
+func (w *RoomWatcher) run(ctx context.Context, c *Client, interval time.Duration) {
+	// events must be closed last, so Err is already set when the consumer sees the end of events
+	defer close(w.events)
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last map[string]Room
+	for {
+		lctx, cancel := context.WithTimeout(ctx, c.opts.timeout)
+		list, err := c.ListRooms(lctx)
+		cancel()
+		if err != nil {
+			w.err = err
+			return
+		}
+		cur := make(map[string]Room, len(list))
+		for _, r := range list {
+			cur[r.ID] = r
+		}
+		for _, ev := range diffRooms(last, cur) {
+			select {
+			case <-ctx.Done():
+				w.err = ctx.Err()
+				return
+			case w.events <- ev:
+			}
+		}
+		last = cur
+		select {
+		case <-ctx.Done():
+			w.err = ctx.Err()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// diffRooms compares two snapshots of the room list and returns events sorted by the room ID.
+func diffRooms(old, cur map[string]Room) []RoomEvent {
+	var out []RoomEvent
+	for id, o := range old {
+		if _, ok := cur[id]; !ok {
+			o := o
+			out = append(out, RoomEvent{Type: RoomRemoved, Old: &o})
+		}
+	}
+	for id, n := range cur {
+		n := n
+		o, ok := old[id]
+		if !ok {
+			out = append(out, RoomEvent{Type: RoomAdded, New: &n})
+		} else if roomChanged(&o, &n) {
+			out = append(out, RoomEvent{Type: RoomChanged, Old: &o, New: &n})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Room().ID < out[j].Room().ID
+	})
+	return out
+}
+
+// roomChanged checks if the room must be reported as changed. Games are compared by the GameInfo,
+// and chat rooms by the number of users. Other fields reported by the server are ignored.
+func roomChanged(o, n *Room) bool {
+	if o.Game != nil || n.Game != nil {
+		return !reflect.DeepEqual(o.Game, n.Game)
+	}
+	return o.Users != n.Users
+}
@@ -0,0 +1,36 @@
+package xwis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffRooms(t *testing.T) {
+	old := map[string]Room{
+		"#chat": {ID: "#chat", Kind: RoomChat, Users: 1},
+		"#game": {ID: "#game", Kind: RoomGame, Users: 2, Game: &GameInfo{Name: "game", Players: 2}},
+		"#gone": {ID: "#gone", Kind: RoomChat},
+	}
+	cur := map[string]Room{
+		// only server-side fields changed
+		"#chat": {ID: "#chat", Kind: RoomChat, Users: 1, ChannelFlags: 388, Reserved: 1},
+		"#game": {ID: "#game", Kind: RoomGame, Users: 2, ChannelUsers: 3, Game: &GameInfo{Name: "game", Players: 2}},
+		"#new":  {ID: "#new", Kind: RoomChat},
+	}
+	evs := diffRooms(old, cur)
+	require.Len(t, evs, 2)
+	require.Equal(t, RoomRemoved, evs[0].Type)
+	require.Equal(t, "#gone", evs[0].Old.ID)
+	require.Equal(t, RoomAdded, evs[1].Type)
+	require.Equal(t, "#new", evs[1].New.ID)
+
+	cur["#chat"] = Room{ID: "#chat", Kind: RoomChat, Users: 2}
+	cur["#game"] = Room{ID: "#game", Kind: RoomGame, Users: 3, Game: &GameInfo{Name: "game", Players: 3}}
+	evs = diffRooms(old, cur)
+	require.Len(t, evs, 4)
+	require.Equal(t, RoomChanged, evs[0].Type)
+	require.Equal(t, "#chat", evs[0].New.ID)
+	require.Equal(t, RoomChanged, evs[1].Type)
+	require.Equal(t, "#game", evs[1].New.ID)
+}
@@ -0,0 +1,120 @@
+package xwis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Direction of the protocol line.
+type Direction int
+
+const (
+	// Sent marks lines sent by the client.
+	Sent = Direction(1)
+	// Received marks lines received from the server.
+	Received = Direction(2)
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Sent:
+		return ">"
+	case Received:
+		return "<"
+	}
+	return fmt.Sprintf("Direction(%d)", int(d))
+}
+
+func parseDirection(s string) (Direction, error) {
+	switch s {
+	case ">":
+		return Sent, nil
+	case "<":
+		return Received, nil
+	}
+	return 0, fmt.Errorf("unsupported direction: %q", s)
+}
+
+// WireHook is called for each raw protocol line sent or received by the client, without the line terminator.
+// It is called synchronously, so it must not block.
+type WireHook func(dir Direction, line string)
+
+// WireEntry is a single line of the wire transcript.
+type WireEntry struct {
+	Time time.Time
+	Dir  Direction
+	Line string
+}
+
+// String formats the entry as a transcript line: time, direction and the raw line.
+func (e WireEntry) String() string {
+	return e.Time.UTC().Format(time.RFC3339Nano) + " " + e.Dir.String() + " " + e.Line
+}
+
+// Recorder writes a timestamped transcript of protocol lines. It is safe for concurrent use.
+//
+// Use Record as a WireHook:
+//
+//	xwis.WithWireHook(rec.Record)
+type Recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+	now func() time.Time
+}
+
+// NewRecorder creates a recorder that writes the transcript to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w, now: time.Now}
+}
+
+// Record adds the line to the transcript. Write errors are available via Err.
+func (r *Recorder) Record(dir Direction, line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	e := WireEntry{Time: r.now(), Dir: dir, Line: line}
+	_, r.err = io.WriteString(r.w, e.String()+eol)
+}
+
+// Err returns the first error that occurred while writing the transcript.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// ReadTranscript reads all entries of the transcript written by Recorder.
+func ReadTranscript(r io.Reader) ([]WireEntry, error) {
+	var out []WireEntry
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+		sub := strings.SplitN(line, " ", 3)
+		if len(sub) != 3 {
+			return nil, fmt.Errorf(pkg+": transcript line %d: unexpected format", n)
+		}
+		t, err := time.Parse(time.RFC3339Nano, sub[0])
+		if err != nil {
+			return nil, fmt.Errorf(pkg+": transcript line %d: %w", n, err)
+		}
+		dir, err := parseDirection(sub[1])
+		if err != nil {
+			return nil, fmt.Errorf(pkg+": transcript line %d: %w", n, err)
+		}
+		out = append(out, WireEntry{Time: t, Dir: dir, Line: sub[2]})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"log"
 )
 
 const (
@@ -11,11 +12,13 @@ const (
 )
 
 type writer struct {
-	bw *bufio.Writer
+	bw   *bufio.Writer
+	log  *log.Logger
+	hook WireHook
 }
 
-func newWriter(w io.Writer) *writer {
-	return &writer{bw: bufio.NewWriter(w)}
+func newWriter(w io.Writer, log *log.Logger, hook WireHook) *writer {
+	return &writer{bw: bufio.NewWriter(w), log: log, hook: hook}
 }
 
 func (w *writer) Flush() error {
@@ -23,17 +26,16 @@ func (w *writer) Flush() error {
 }
 
 func (w *writer) WriteLine(line string) error {
-	if DebugLog != nil {
-		DebugLog.Println(line)
+	if w.log != nil {
+		w.log.Println(line)
+	}
+	if w.hook != nil {
+		w.hook(Sent, line)
 	}
 	_, err := w.bw.WriteString(line + eol)
 	return err
 }
 
 func (w *writer) WriteLinef(format string, args ...interface{}) error {
-	if DebugLog != nil {
-		DebugLog.Printf(format+"\n", args...)
-	}
-	_, err := fmt.Fprintf(w.bw, format+eol, args...)
-	return err
+	return w.WriteLine(fmt.Sprintf(format, args...))
 }
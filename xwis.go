@@ -2,6 +2,7 @@ package xwis
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -17,17 +18,15 @@ import (
 )
 
 var (
-	dialer     net.Dialer
-	rander     = rand.New(rand.NewSource(time.Now().UnixNano()))
-	lobbyNames = []string{
-		"Brin",
-		"Ix",
-		"Dun Mir",
-	}
+	dialer net.Dialer
+
+	randMu sync.Mutex // rand.Rand is not safe for concurrent use
+	rander = rand.New(rand.NewSource(time.Now().UnixNano()))
 )
 
 var (
 	ErrClientClosed = errors.New("client closed")
+	ErrPingTimeout  = errors.New("ping timeout")
 )
 
 const (
@@ -43,32 +42,47 @@ type LobbyServer struct {
 }
 
 func randomLogin() string {
-	return fmt.Sprintf("probe%04x", rander.Intn(0x10000))
+	randMu.Lock()
+	v := rander.Intn(0x10000)
+	randMu.Unlock()
+	return fmt.Sprintf("probe%04x", v)
+}
+
+func ListLobbyServers(ctx context.Context, opts ...Option) ([]LobbyServer, error) {
+	return ListLobbyServersWithAddress(ctx, DefaultAddress, opts...)
 }
 
-func ListLobbyServers(ctx context.Context) ([]LobbyServer, error) {
-	conn, err := dialer.DialContext(ctx, "tcp", DefaultAddress)
+func ListLobbyServersWithAddress(ctx context.Context, addr string, opts ...Option) ([]LobbyServer, error) {
+	o := newOptions(opts)
+	conn, err := o.dial(ctx, "tcp", addr)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 
-	name := randomLogin()
-	return listLobbyServers(ctx, conn, name)
+	name := o.randomLogin()
+	return listLobbyServers(ctx, conn, o, name)
 }
 
-func listLobbyServers(ctx context.Context, conn net.Conn, name string) ([]LobbyServer, error) {
-	w := newWriter(conn)
-	if err := w.WriteLine("verchk 32512 65551"); err != nil {
+// ListProductLobbyServers lists lobby servers for a given product.
+// It is a shorthand for ListLobbyServersWithAddress with WithProduct option.
+func ListProductLobbyServers(ctx context.Context, prod *Product, addr string, opts ...Option) ([]LobbyServer, error) {
+	return ListLobbyServersWithAddress(ctx, addr, append(opts, WithProduct(prod))...)
+}
+
+func listLobbyServers(ctx context.Context, conn net.Conn, o *options, name string) ([]LobbyServer, error) {
+	prod := o.prod
+	w := newWriter(conn, o.logger(), o.wire)
+	if err := w.WriteLinef("verchk %d %d", wolSKU, prod.APIVersion); err != nil {
 		return nil, err
 	}
-	if err := w.WriteLine("verchk 9472 65540"); err != nil {
+	if err := w.WriteLinef("verchk %d %d", prod.SKU, prod.Version); err != nil {
 		return nil, err
 	}
-	if err := w.WriteLine("lobcount 9472"); err != nil {
+	if err := w.WriteLinef("lobcount %d", prod.SKU); err != nil {
 		return nil, err
 	}
-	if err := w.WriteLinef("whereto %s %s 9472 65540 2227973051451322323085", name, name); err != nil {
+	if err := w.WriteLinef("whereto %s %s %d %d %s", name, name, prod.SKU, prod.Version, prod.Serial); err != nil {
 		return nil, err
 	}
 	if err := w.WriteLine("QUIT"); err != nil {
@@ -80,7 +94,7 @@ func listLobbyServers(ctx context.Context, conn net.Conn, name string) ([]LobbyS
 	done := ctx.Done()
 
 	var out []LobbyServer
-	r := newReader(conn)
+	r := newReader(conn, o.logger(), o.wire)
 	for {
 		select {
 		case <-done:
@@ -123,13 +137,14 @@ func listLobbyServers(ctx context.Context, conn net.Conn, name string) ([]LobbyS
 	}
 }
 
-func NewClient(ctx context.Context, login, pass string) (*Client, error) {
-	return NewClientWithAddress(ctx, DefaultAddress, login, pass)
+func NewClient(ctx context.Context, login, pass string, opts ...Option) (*Client, error) {
+	return NewClientWithAddress(ctx, DefaultAddress, login, pass, opts...)
 }
 
-func NewClientWithAddress(ctx context.Context, addr, login, pass string) (*Client, error) {
+func NewClientWithAddress(ctx context.Context, addr, login, pass string, opts ...Option) (*Client, error) {
+	o := newOptions(opts)
 	if login == "" {
-		login = randomLogin()
+		login = o.randomLogin()
 	}
 	if pass == "" {
 		pass = login
@@ -141,84 +156,117 @@ func NewClientWithAddress(ctx context.Context, addr, login, pass string) (*Clien
 	if err != nil {
 		return nil, err
 	}
-	conn, err := dialer.DialContext(ctx, "tcp", addr)
-	if err != nil {
+	c := newClient(o, addr, host, login, pass)
+	if err := c.connect(ctx); err != nil {
 		return nil, err
 	}
-	c := &Client{
-		c:     conn,
-		w:     newWriter(conn),
-		r:     newReader(conn),
-		login: login,
-		stop:  make(chan struct{}),
+	c.start()
+	if o.keepAlive > 0 {
+		c.KeepAlive(o.keepAlive, o.keepAliveTimeout)
 	}
-	if err := c.handshake(ctx, host, pass); err != nil {
-		_ = conn.Close()
-		return nil, err
-	}
-	go c.readLoop()
 	return c, nil
 }
 
-type readStream struct {
-	c    *Client
-	stop chan struct{}
-	msg  chan *irc.Message
-	errc chan error
+// NewProductClient connects to the lobby server and logs in as a client of a given product.
+// It is a shorthand for NewClientWithAddress with WithProduct option.
+func NewProductClient(ctx context.Context, prod *Product, addr, login, pass string, opts ...Option) (*Client, error) {
+	return NewClientWithAddress(ctx, addr, login, pass, append(opts, WithProduct(prod))...)
 }
 
-func (s *readStream) WaitFor(ctx context.Context, cmds ...string) (*irc.Message, error) {
-	done := ctx.Done()
-	for {
-		var m *irc.Message
-		select {
-		case <-s.c.stop:
-			return nil, ErrClientClosed
-		case <-done:
-			return nil, ctx.Err()
-		case err := <-s.errc:
-			return nil, fmt.Errorf(pkg+": wait(%s): %w", strings.Join(cmds, "|"), err)
-		case m = <-s.msg:
-		}
-		for _, c := range cmds {
-			if c == m.Command {
-				return m, nil
-			}
-		}
+func newClient(o *options, addr, host, login, pass string) *Client {
+	return &Client{
+		opts:     o,
+		prod:     o.prod,
+		reconn:   o.reconn,
+		addr:     addr,
+		host:     host,
+		login:    login,
+		pass:     pass,
+		stop:     make(chan struct{}),
+		dead:     make(chan struct{}),
+		list:     make(chan struct{}, 1),
+		chat:     make(chan ChatMessage, chatBufferSize),
+		subs:     make(map[*Subscription]struct{}),
+		games:    make(map[*Game]struct{}),
+		channels: make(map[string]struct{}),
 	}
 }
 
-func (s *readStream) Close() error {
-	s.c.mu.Lock()
-	defer s.c.mu.Unlock()
-	if s.c.read == s {
-		s.c.read = nil
-	}
-	close(s.stop)
-	return nil
+func (c *Client) start() {
+	go c.chatLoop(c.Subscribe(MatchCommands("PRIVMSG"), chatBufferSize))
+	go c.readLoop()
 }
 
 type Client struct {
-	login string
-	mu    sync.Mutex
-	c     net.Conn
-	w     *writer
-	r     *reader // owned by readLoop
-	stop  chan struct{}
-	read  *readStream
+	opts   *options
+	prod   *Product
+	addr   string
+	host   string
+	login  string
+	pass   string
+	reconn *ReconnectConfig
+
+	mu       sync.Mutex // protects writes
+	c        net.Conn
+	w        *writer
+	r        *reader // owned by readLoop
+	games    map[*Game]struct{}
+	channels map[string]struct{}
+	ferr     error // reason why the connection was closed by the client
+
+	stop chan struct{}
+	dead chan struct{} // closed when readLoop exits
+	list chan struct{} // serializes LIST requests
+	chat chan ChatMessage
+
+	smu  sync.Mutex
+	subs map[*Subscription]struct{}
+	rerr error // set when readLoop exits
+
+	stmu  sync.Mutex
+	stats Stats
 }
 
-func (c *Client) curStream() *readStream {
+// connect dials the server, performs the handshake and replaces the current connection.
+func (c *Client) connect(ctx context.Context) error {
+	conn, err := c.opts.dial(ctx, "tcp", c.addr)
+	if err != nil {
+		return err
+	}
+	w, r := newWriter(conn, c.log(), c.opts.wire), newReader(conn, c.log(), c.opts.wire)
+	stop := interruptOnCancel(ctx, conn.SetDeadline)
+	err = c.handshake(ctx, conn, w, r)
+	stop()
+	if cerr := ctx.Err(); cerr != nil {
+		// the deadline may be already reset by the watcher
+		err = cerr
+	}
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return c.read
+	select {
+	case <-c.stop:
+		_ = conn.Close()
+		return ErrClientClosed
+	default:
+	}
+	if c.c != nil {
+		_ = c.c.Close()
+	}
+	c.c, c.w, c.r = conn, w, r
+	c.ferr = nil
+	return nil
 }
 
-func (c *Client) readLoop() {
+// readMessages reads and dispatches messages until the connection fails.
+func (c *Client) readMessages() error {
 	for {
 		select {
 		case <-c.stop:
-			return
+			return ErrClientClosed
 		default:
 		}
 		m, err := c.r.ReadMessage()
@@ -226,32 +274,65 @@ func (c *Client) readLoop() {
 			err = io.ErrUnexpectedEOF
 		}
 		if err != nil {
-			err = fmt.Errorf(pkg+": %w", err)
-			if DebugLog != nil {
-				DebugLog.Println(err)
+			select {
+			case <-c.stop:
+				return ErrClientClosed
+			default:
 			}
-			if s := c.curStream(); s != nil {
-				select {
-				case <-c.stop:
-				case <-s.stop:
-				case s.errc <- err:
-				}
+			c.mu.Lock()
+			if c.ferr != nil {
+				err, c.ferr = c.ferr, nil
 			}
-			return
+			c.mu.Unlock()
+			err = fmt.Errorf(pkg+": %w", err)
+			if log := c.log(); log != nil {
+				log.Println(err)
+			}
+			return err
 		}
-		if DebugLog != nil {
-			DebugLog.Println(m)
+		if log := c.log(); log != nil {
+			log.Println(m)
 		}
-		if s := c.curStream(); s != nil {
-			select {
-			case <-c.stop:
-			case <-s.stop:
-			case s.msg <- m:
-			}
+		if m.Command == "PING" {
+			c.writePong(m)
 		}
+		c.dispatch(m)
 	}
 }
 
+func (c *Client) readLoop() {
+	defer close(c.dead)
+	for {
+		err := c.readMessages()
+		if err == ErrClientClosed || c.reconn == nil {
+			c.stopSubs(err)
+			return
+		}
+		if err = c.reconnect(err); err != nil {
+			c.stopSubs(err)
+			return
+		}
+	}
+}
+
+// Done returns a channel that is closed when the client is closed or the connection is lost.
+// In the reconnecting mode, the channel is closed only when all reconnect attempts fail.
+func (c *Client) Done() <-chan struct{} {
+	return c.dead
+}
+
+// Err returns the reason why the client stopped. It returns nil if the client is still active.
+func (c *Client) Err() error {
+	select {
+	case <-c.dead:
+	default:
+		return nil
+	}
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	return c.rerr
+}
+
 func (c *Client) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -267,184 +348,399 @@ func (c *Client) Close() error {
 	return c.c.Close()
 }
 
-func getDeadline(ctx context.Context) time.Time {
+func getDeadline(ctx context.Context, timeout time.Duration) time.Time {
 	deadline, ok := ctx.Deadline()
 	if !ok {
-		deadline = time.Now().Add(defaultTimeout)
+		deadline = time.Now().Add(timeout)
 	}
 	return deadline
 }
 
-func (c *Client) handshake(ctx context.Context, host, pass string) error {
+// log returns the logger for debug messages, or nil if logging is disabled.
+func (c *Client) log() *log.Logger {
+	return c.opts.logger()
+}
+
+func (c *Client) handshake(ctx context.Context, conn net.Conn, w *writer, r *reader) error {
 	const (
 		versCheck = false
-		setCP     = false
 		setOpt    = false
 	)
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	deadline := getDeadline(ctx)
-	if err := c.c.SetWriteDeadline(deadline); err != nil {
+	cp := c.opts.codePage
+	deadline := getDeadline(ctx, c.opts.handshakeTimeout)
+	if err := conn.SetWriteDeadline(deadline); err != nil {
 		return err
 	}
-	defer c.c.SetWriteDeadline(time.Time{})
-	if err := c.w.WriteLine("CVERS 11015 9472"); err != nil {
+	defer conn.SetWriteDeadline(time.Time{})
+	if err := w.WriteLinef("CVERS %d %d", c.prod.ClientVersion, c.prod.SKU); err != nil {
 		return err
 	}
-	if err := c.w.WriteLine("PASS supersecret"); err != nil {
+	if err := w.WriteLine("PASS supersecret"); err != nil {
 		return err
 	}
-	if err := c.w.WriteLinef("NICK %s", c.login); err != nil {
+	if err := w.WriteLinef("NICK %s", c.login); err != nil {
 		return err
 	}
-	if err := c.w.WriteLinef("apgar %s 0", pass); err != nil {
+	if err := w.WriteLinef("apgar %s 0", Apgar(c.pass)); err != nil {
 		return err
 	}
-	if err := c.w.WriteLinef("USER UserName HostName %s :RealName", host); err != nil {
+	if err := w.WriteLinef("USER UserName HostName %s :RealName", c.host); err != nil {
 		return err
 	}
 	if versCheck {
-		if err := c.w.WriteLine("verchk 32512 720911"); err != nil {
+		if err := w.WriteLinef("verchk %d 720911", wolSKU); err != nil {
 			return err
 		}
 	}
 	if setOpt {
-		if err := c.w.WriteLine("SETOPT 17,33"); err != nil {
+		if err := w.WriteLine("SETOPT 17,33"); err != nil {
 			return err
 		}
 	}
-	if setCP {
-		if err := c.w.WriteLine("SETCODEPAGE 1252"); err != nil {
+	if cp != CodePageNone {
+		if err := w.WriteLinef("SETCODEPAGE %d", int(cp)); err != nil {
 			return err
 		}
 	}
-	if err := c.w.Flush(); err != nil {
+	if err := w.Flush(); err != nil {
 		return err
 	}
 
-	if err := c.c.SetReadDeadline(deadline); err != nil {
+	if err := conn.SetReadDeadline(deadline); err != nil {
 		return err
 	}
-	defer c.c.SetReadDeadline(time.Time{})
+	defer conn.SetReadDeadline(time.Time{})
 
 	// login itself
-	if _, err := c.r.WaitFor(ctx, "376"); err != nil {
+	if _, err := r.WaitFor(ctx, "376"); err != nil {
 		return err
 	}
 
 	if versCheck {
-		if _, err := c.r.WaitFor(ctx, "379"); err != nil {
+		if _, err := r.WaitFor(ctx, "379"); err != nil {
 			return err
 		}
 	}
-	if setCP {
-		if _, err := c.r.WaitFor(ctx, "329"); err != nil {
+	if cp != CodePageNone {
+		m, err := r.WaitFor(ctx, "329")
+		if err != nil {
 			return err
 		}
+		// the server replies with the code page it will use
+		if len(m.Params) >= 2 && m.Params[1] != strconv.Itoa(int(cp)) {
+			return fmt.Errorf(pkg+": server does not support code page %v: got %q", cp, m.Params[1])
+		}
+	}
+	return nil
+}
+
+const (
+	RoomChat  = RoomKind(0) // chat room created by a user
+	RoomLobby = RoomKind(1) // lobby chat room created by the server
+	RoomGame  = RoomKind(2) // game channel
+)
+
+// RoomKind is a kind of the room in the room list.
+type RoomKind int
+
+func (k RoomKind) Unknown() bool {
+	switch k {
+	case RoomChat, RoomLobby, RoomGame:
+		return false
+	}
+	return true
+}
+
+func (k RoomKind) String() string {
+	switch k {
+	case RoomChat:
+		return "chat"
+	case RoomLobby:
+		return "lobby"
+	case RoomGame:
+		return "game"
+	}
+	return fmt.Sprintf("RoomKind(%d)", int(k))
+}
+
+func (k RoomKind) MarshalJSON() ([]byte, error) {
+	if k.Unknown() {
+		return json.Marshal(int(k))
+	}
+	return json.Marshal(k.String())
+}
+
+func (k *RoomKind) UnmarshalJSON(data []byte) error {
+	var v int
+	err := json.Unmarshal(data, &v)
+	if err == nil {
+		*k = RoomKind(v)
+		return nil
+	}
+	var s string
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	switch s {
+	case "chat":
+		*k = RoomChat
+	case "lobby":
+		*k = RoomLobby
+	case "game":
+		*k = RoomGame
+	default:
+		return fmt.Errorf("unsupported room kind: %q", s)
 	}
 	return nil
 }
 
 type Room struct {
-	ID    string
-	Name  string
-	Users int
-	Game  *GameInfo
+	ID     string         `json:"id"`
+	Name   string         `json:"name"`
+	Kind   RoomKind       `json:"kind"`
+	Users  int            `json:"users"`
+	Game   *GameInfo      `json:"game,omitempty"`
+	Header *PayloadHeader `json:"header,omitempty"`
+
+	// Reserved is a parameter of the room list with an unknown meaning. It is usually zero.
+	// Its position in 326 and 327 lines is presumed and was only checked against the fake server.
+	Reserved uint32 `json:"reserved,omitempty"`
+
+	// Fields below are only set for chat rooms.
+
+	// ChannelFlags are presumably flags of the chat channel, as reported by the server. Their meaning is unknown,
+	// lobbies on XWIS report 388.
+	ChannelFlags uint32 `json:"channel_flags,omitempty"`
+
+	// Fields below are only set for game rooms, and are reported by the server, not by the host.
+
+	// ChannelUsers is the number of users in the game channel.
+	// Unlike Users, it does not depend on the player count advertised by the host.
+	ChannelUsers int `json:"channel_users,omitempty"`
+	// MaxUsers is the max number of users in the game channel, as requested by the host with JOINGAME.
+	MaxUsers int `json:"max_users,omitempty"`
+	// GameType is presumably the game ID of the product the game was created for.
+	// Meaning of this field and of Tournament was not verified on a captured room list.
+	GameType int `json:"game_type,omitempty"`
+	// Tournament is presumably set for tournament games.
+	Tournament bool `json:"tournament,omitempty"`
+	// Addr is the IP address of the host.
+	Addr string `json:"addr,omitempty"`
+
+	// Params are raw parameters of the room list line, excluding the nick. Meaning of most of them was only
+	// checked against the fake server, so they are provided in case the typed fields above are wrong.
+	Params []string `json:"params,omitempty"`
 }
 
-func (c *Client) newStreamUnsafe() *readStream {
-	c.read = &readStream{
-		c:    c,
-		stop: make(chan struct{}),
-		msg:  make(chan *irc.Message, 5),
-		errc: make(chan error, 1),
+// Full checks if the game channel has no room for more users.
+func (r *Room) Full() bool {
+	return r.MaxUsers > 0 && r.ChannelUsers >= r.MaxUsers
+}
+
+func (c *Client) lockList(ctx context.Context) error {
+	select {
+	case c.list <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.stop:
+		return ErrClientClosed
 	}
-	return c.read
 }
 
-func (c *Client) lockWhenAvailable(ctx context.Context) error {
-	for {
-		c.mu.Lock()
-		s := c.read
-		if s == nil {
-			return nil // still holding mutex
+func (c *Client) unlockList() {
+	<-c.list
+}
+
+// writeReq locks the connection and calls fnc to write the request. The write honors the context:
+// if it is cancelled or the deadline is reached, the write fails. Since the request may be written
+// partially in this case, the connection is closed.
+func (c *Client) writeReq(ctx context.Context, fnc func(w *writer) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conn := c.c
+	if err := conn.SetWriteDeadline(getDeadline(ctx, c.opts.timeout)); err != nil {
+		return err
+	}
+	defer conn.SetWriteDeadline(time.Time{})
+	stop := interruptOnCancel(ctx, conn.SetWriteDeadline)
+	err := fnc(c.w)
+	if err == nil {
+		err = c.w.Flush()
+	}
+	stop()
+	if err != nil {
+		if cerr := ctx.Err(); cerr != nil {
+			err = cerr
 		}
-		c.mu.Unlock()
+		c.ferr = err
+		_ = conn.Close()
+	}
+	return err
+}
+
+// interruptOnCancel sets the connection deadline to now when the context is cancelled,
+// which interrupts all blocked operations. The returned function stops watching the context.
+func interruptOnCancel(ctx context.Context, setDeadline func(t time.Time) error) func() {
+	done := ctx.Done()
+	if done == nil {
+		return func() {}
+	}
+	stop, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
 		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-c.stop:
-			return ErrClientClosed
-		case <-s.stop:
+		case <-done:
+			_ = setDeadline(time.Now())
+		case <-stop:
 		}
+	}()
+	return func() {
+		close(stop)
+		<-exited
 	}
 }
 
-func (c *Client) writeListRoomsReq(ctx context.Context) (*readStream, error) {
-	if err := c.lockWhenAvailable(ctx); err != nil {
+func (c *Client) writeListRoomsReq(ctx context.Context, prod *Product) (*Subscription, error) {
+	read := c.request(MatchAny(MatchCommands("326", "327", "323"), matchErrors("")), 64)
+	err := c.writeReq(ctx, func(w *writer) error {
+		return w.WriteLinef("LIST -1 %d", prod.GameID)
+	})
+	if err != nil {
+		read.Close()
 		return nil, err
 	}
-	defer c.mu.Unlock()
-	deadline := getDeadline(ctx)
-	if err := c.c.SetDeadline(deadline); err != nil {
+	return read, nil
+}
+
+// Product returns the product profile used by the client.
+func (c *Client) Product() *Product {
+	return c.prod
+}
+
+// ListRooms lists all available rooms on XWIS.
+func (c *Client) ListRooms(ctx context.Context) ([]Room, error) {
+	return c.ListProductRooms(ctx, c.prod)
+}
+
+// ListProductRooms lists all available rooms for a given product.
+func (c *Client) ListProductRooms(ctx context.Context, prod *Product) ([]Room, error) {
+	if err := c.lockList(ctx); err != nil {
 		return nil, err
 	}
-	defer c.c.SetDeadline(time.Time{})
-	// TODO: 37 is probably a game ID (Nox)
-	if err := c.w.WriteLine("LIST -1 37"); err != nil {
+	start := time.Now()
+	read, err := c.writeListRoomsReq(ctx, prod)
+	if err != nil {
+		c.unlockList()
+		c.updateStats(func(s *Stats) {
+			s.ListErrors++
+		})
 		return nil, err
 	}
-	if err := c.w.Flush(); err != nil {
+	out, err := c.readListRooms(ctx, prod, read)
+	dt := time.Since(start)
+	c.updateStats(func(s *Stats) {
+		if err != nil {
+			s.ListErrors++
+		} else {
+			s.ListRequests++
+			s.ListDuration += dt
+		}
+	})
+	if err != nil && ctx.Err() != nil && read.Err() == nil {
+		// The server will still send the rest of the list. Drain it in background,
+		// so it won't be mixed with the reply to the next request.
+		go c.drainList(read)
 		return nil, err
 	}
-	return c.newStreamUnsafe(), nil
+	_ = read.Close()
+	c.unlockList()
+	return out, err
 }
 
-// ListRooms lists all available rooms on XWIS.
-func (c *Client) ListRooms(ctx context.Context) ([]Room, error) {
-	read, err := c.writeListRoomsReq(ctx)
-	if err != nil {
-		return nil, err
-	}
+// listDrainIdle is the max time to wait for the next line of the room list when draining it.
+const listDrainIdle = 2 * time.Second
+
+// drainList waits for the end of the room list and releases the list lock.
+//
+// The drain is bounded by listDrainIdle between lines, so a list that never ends does not block
+// other requests for the whole client timeout.
+func (c *Client) drainList(read *Subscription) {
+	defer c.unlockList()
 	defer read.Close()
+	ctx, cancel := c.stopContext(c.opts.timeout)
+	defer cancel()
+	for {
+		lctx, lcancel := context.WithTimeout(ctx, listDrainIdle)
+		m, err := read.Next(lctx)
+		lcancel()
+		if err != nil || m.Command == "323" || isErrorReply(m) {
+			return
+		}
+	}
+}
+
+// rawParams returns a copy of the room list line parameters, excluding the nick.
+func rawParams(m *irc.Message) []string {
+	return append([]string{}, m.Params[1:]...)
+}
+
+func (c *Client) readListRooms(ctx context.Context, prod *Product, read *Subscription) ([]Room, error) {
 	var out []Room
 	for {
-		var m *irc.Message
-		select {
-		case <-c.stop:
-			return nil, ErrClientClosed
-		case err := <-read.errc:
-			if err == io.EOF {
-				err = io.ErrUnexpectedEOF
-			}
+		m, err := read.Next(ctx)
+		if err != nil {
 			return nil, err
-		case m = <-read.msg:
 		}
 		switch m.Command {
 		case "326": // game
 			if len(m.Params) != 9 {
 				return nil, fmt.Errorf(pkg+": unexpected line: %q", m.String())
 			}
-			id := m.Params[1]
-			name := strings.TrimPrefix(id, "#")
-			payload := m.Params[8]
-			info, err := decryptAndDecode([]byte(payload))
-			if err != nil {
-				log.Printf("cannot parse game info: %v", err)
-			} else {
-				if v, err := strconv.ParseUint(m.Params[7], 10, 32); err != nil {
-					log.Printf("cannot parse game addr: %v", err)
-				} else {
-					ip := net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v>>0))
-					info.Addr = ip.String()
+			id := c.opts.codePage.DecodeString(m.Params[1])
+			r := Room{
+				ID:     id,
+				Name:   strings.TrimPrefix(id, "#"),
+				Kind:   RoomGame,
+				Params: rawParams(m),
+			}
+			parse := func(i int, field string, bits int) uint64 {
+				v, err := strconv.ParseUint(m.Params[i], 10, bits)
+				if err != nil {
+					// ParseUint returns the max value on overflow
+					if log := c.log(); log != nil {
+						log.Printf("cannot parse game %s: %v", field, err)
+					}
+					return 0
 				}
+				return v
 			}
-			r := Room{
-				ID:   id,
-				Name: name,
-				Game: info,
+			r.ChannelUsers = int(parse(2, "users", 16))
+			r.MaxUsers = int(parse(3, "max users", 16))
+			r.GameType = int(parse(4, "type", 16))
+			r.Tournament = parse(5, "tournament", 8) != 0
+			r.Reserved = uint32(parse(6, "reserved", 32))
+			if v := parse(7, "addr", 32); v != 0 {
+				r.Addr = net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v>>0)).String()
 			}
-			if info != nil {
+			payload := m.Params[8]
+			hdr, info, err := decodePayload([]byte(payload))
+			r.Header = hdr
+			if err != nil {
+				c.updateStats(func(s *Stats) {
+					s.DecodeFailures++
+				})
+				if log := c.log(); log != nil {
+					log.Printf("cannot parse game info: %v", err)
+				}
+			} else {
+				c.opts.codePage.decodeGameInfo(info)
+				info.Addr = r.Addr
+				r.Game = info
 				r.Name = info.Name
 				r.Users = info.Players
 			}
@@ -453,82 +749,98 @@ func (c *Client) ListRooms(ctx context.Context) ([]Room, error) {
 			if len(m.Params) != 5 {
 				return nil, fmt.Errorf(pkg+": unexpected line: %q", m.String())
 			}
-			id := m.Params[1]
+			id := c.opts.codePage.DecodeString(m.Params[1])
 			name := strings.TrimPrefix(id, "#")
-			if strings.HasPrefix(name, "Lob_37_") {
-				ind, err := strconv.ParseUint(name[7:], 10, 8)
-				if err == nil && ind >= 0 && int(ind) < len(lobbyNames) {
-					name = lobbyNames[ind]
-				}
-			}
 			num, err := strconv.ParseUint(m.Params[2], 10, 16)
 			if err != nil {
 				return nil, fmt.Errorf(pkg+": %w", err)
 			}
-			out = append(out, Room{
-				ID:    id,
-				Name:  name,
-				Users: int(num),
-			})
+			r := Room{
+				ID:     id,
+				Name:   prod.lobbyName(name),
+				Kind:   RoomChat,
+				Users:  int(num),
+				Params: rawParams(m),
+			}
+			if _, ok := prod.lobbyIndex(name); ok {
+				r.Kind = RoomLobby
+			}
+			if v, err := strconv.ParseUint(m.Params[3], 10, 32); err != nil {
+				if log := c.log(); log != nil {
+					log.Printf("cannot parse chat reserved: %v", err)
+				}
+			} else {
+				r.Reserved = uint32(v)
+			}
+			if v, err := strconv.ParseUint(m.Params[4], 10, 32); err != nil {
+				if log := c.log(); log != nil {
+					log.Printf("cannot parse chat flags: %v", err)
+				}
+			} else {
+				r.ChannelFlags = uint32(v)
+			}
+			out = append(out, r)
 		case "323":
 			return out, nil
+		default:
+			if isErrorReply(m) {
+				return nil, newServerError(m)
+			}
 		}
 	}
 }
 
-func (c *Client) writeNewChannelReq(ctx context.Context, info *GameInfo) (string, *readStream, error) {
-	if err := c.lockWhenAvailable(ctx); err != nil {
-		return "", nil, err
-	}
-	defer c.mu.Unlock()
+func (c *Client) writeNewChannelReq(ctx context.Context, info *GameInfo) (string, *Subscription, error) {
 	channel := fmt.Sprintf("#%s's_game", c.login)
-	if err := c.w.WriteLinef("JOINGAME %s 1 %d 37 3 1 1 13893824", channel, info.MaxPlayers); err != nil {
-		return "", nil, err
-	}
-	if err := c.w.Flush(); err != nil {
+	read := c.request(MatchAny(MatchAll(MatchCommands("366"), MatchChannel(channel)), matchErrors(channel)), 0)
+	err := c.writeReq(ctx, func(w *writer) error {
+		return w.WriteLinef("JOINGAME %s 1 %d %d 3 1 1 13893824", channel, info.MaxPlayers, c.prod.GameID)
+	})
+	if err != nil {
+		read.Close()
 		return "", nil, err
 	}
-	return channel, c.newStreamUnsafe(), nil
+	return channel, read, nil
 }
 
 func (c *Client) writeStartGameReq(ctx context.Context, channel string, info *GameInfo) error {
-	payload, err := encodeAndEncrypt(info)
+	payload, err := c.encodePayload(info)
 	if err != nil {
 		return err
 	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if err := c.w.WriteLinef("TOPIC %s %s", channel, string(payload)); err != nil {
-		return err
-	}
-	if false {
-		if err := c.w.WriteLinef("STARTG %s %s", channel, c.login); err != nil {
+	return c.writeReq(ctx, func(w *writer) error {
+		if err := w.WriteLinef("TOPIC %s %s", channel, string(payload)); err != nil {
 			return err
 		}
-		if err := c.w.WriteLinef("TOPIC %s %s", channel, string(payload)); err != nil {
-			return err
+		if false {
+			if err := w.WriteLinef("STARTG %s %s", channel, c.login); err != nil {
+				return err
+			}
+			if err := w.WriteLinef("TOPIC %s %s", channel, string(payload)); err != nil {
+				return err
+			}
 		}
-	}
-	if err := c.w.Flush(); err != nil {
-		return err
-	}
-	return nil
+		return nil
+	})
 }
 
 func (c *Client) writeUpdateGameReq(ctx context.Context, channel string, info *GameInfo) error {
-	payload, err := encodeAndEncrypt(info)
+	payload, err := c.encodePayload(info)
 	if err != nil {
 		return err
 	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if err := c.w.WriteLinef("TOPIC %s %s", channel, string(payload)); err != nil {
-		return err
-	}
-	if err := c.w.Flush(); err != nil {
-		return err
+	return c.writeReq(ctx, func(w *writer) error {
+		return w.WriteLinef("TOPIC %s %s", channel, string(payload))
+	})
+}
+
+// encodePayload converts text fields of the game info to the client code page, then encodes and encrypts it.
+func (c *Client) encodePayload(info *GameInfo) ([]byte, error) {
+	info, err := c.opts.codePage.encodeGameInfo(info)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return encodeAndEncrypt(info)
 }
 
 func (c *Client) writeHostGameReq(ctx context.Context, info *GameInfo) (string, error) {
@@ -536,21 +848,36 @@ func (c *Client) writeHostGameReq(ctx context.Context, info *GameInfo) (string,
 	if err != nil {
 		return "", err
 	}
-	if _, err := read.WaitFor(ctx, "366"); err != nil {
-		return "", err
-	}
+	_, err = read.WaitFor(ctx, "366")
 	_ = read.Close()
-	if err := c.writeStartGameReq(ctx, channel, info); err != nil {
+	if err == nil {
+		err = c.writeStartGameReq(ctx, channel, info)
+	}
+	if err != nil {
+		// JOINGAME was already sent, so the server may still create the channel
+		c.leaveChannel(channel)
 		return "", err
 	}
 	return channel, nil
 }
 
-func (c *Client) writeStopGameReq(channel string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	_ = c.w.WriteLinef("PART %s", channel)
-	return c.w.Flush()
+// leaveChannel sends PART for a channel that was joined by a failed or cancelled request.
+func (c *Client) leaveChannel(channel string) {
+	ctx, cancel := c.stopContext(c.opts.timeout)
+	defer cancel()
+	if err := c.writePartReq(ctx, channel); err != nil {
+		if log := c.log(); log != nil {
+			log.Printf("cannot leave %s: %v", channel, err)
+		}
+	}
+}
+
+// checkGameInfo validates the game info, if strict mode is enabled.
+func (c *Client) checkGameInfo(info *GameInfo) error {
+	if !c.opts.strictGameInfo {
+		return nil
+	}
+	return info.ValidateCodePage(c.opts.codePage)
 }
 
 // HostGame registers a game and keeps it online until the context is cancelled.
@@ -562,8 +889,8 @@ func (c *Client) HostGame(ctx context.Context, info GameInfo) error {
 	}
 	defer g.Close()
 	select {
-	case <-c.stop:
-		return ErrClientClosed
+	case <-c.dead:
+		return c.Err()
 	case <-ctx.Done():
 	}
 	return nil
@@ -578,7 +905,13 @@ type Game struct {
 
 // Update info for this game.
 func (g *Game) Update(ctx context.Context, info GameInfo) error {
+	if err := g.c.checkGameInfo(&info); err != nil {
+		return err
+	}
 	info.setDefaults()
+	g.c.mu.Lock()
+	g.info = info
+	g.c.mu.Unlock()
 	return g.c.writeUpdateGameReq(ctx, g.channel, &info)
 }
 
@@ -588,15 +921,25 @@ func (g *Game) Close() error {
 		return nil
 	}
 	g.closed = true
-	return g.c.writeStopGameReq(g.channel)
+	g.c.mu.Lock()
+	delete(g.c.games, g)
+	g.c.mu.Unlock()
+	return g.c.writePartReq(context.Background(), g.channel)
 }
 
 // RegisterGame register the game online and allows to control it asynchronously.
 func (c *Client) RegisterGame(ctx context.Context, info GameInfo) (*Game, error) {
+	if err := c.checkGameInfo(&info); err != nil {
+		return nil, err
+	}
 	info.setDefaults()
 	channel, err := c.writeHostGameReq(ctx, &info)
 	if err != nil {
 		return nil, err
 	}
-	return &Game{c: c, info: info, channel: channel}, nil
+	g := &Game{c: c, info: info, channel: channel}
+	c.mu.Lock()
+	c.games[g] = struct{}{}
+	c.mu.Unlock()
+	return g, nil
 }
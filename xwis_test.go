@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -137,3 +138,17 @@ func TestListLobbyServers(t *testing.T) {
 		{Addr: "xwis.net:4000", Name: "XWIS"},
 	}, list)
 }
+
+func TestRandomLoginConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				require.Len(t, randomLogin(), maxLogin)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,743 @@
+// Package xwistest implements an in-process fake XWIS lobby server for tests.
+//
+// The server speaks the same subset of the protocol as xwis.Client: login, room listing,
+// game hosting and lobby server discovery.
+package xwistest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/irc.v3"
+
+	"github.com/noxworld-dev/xwis"
+)
+
+const (
+	// ServerName is used as a prefix for all server replies.
+	ServerName = "xwistest"
+	// payloadPrefix is sent before the topic in the game list. Its meaning is unknown.
+	payloadPrefix = "128"
+)
+
+// Game is a game registered on the server.
+type Game struct {
+	// Channel is a channel name of the game, including the '#' prefix.
+	Channel string
+	// Host is the nick of the user that created the game.
+	Host string
+	// Users is the number of users in the game channel.
+	Users int
+	// MaxPlayers is the max number of players, as requested in JOINGAME.
+	MaxPlayers int
+	// Topic is the raw topic (encoded game info) set by the host.
+	Topic string
+	// Info is the decoded game info. It is nil if the topic was not set or cannot be decoded.
+	Info *xwis.GameInfo
+}
+
+// Message is a chat message sent by one of the users.
+type Message struct {
+	// From is the nick of the sender.
+	From string
+	// To is the channel (with '#' prefix) or a nick of the recipient.
+	To string
+	// Text of the message.
+	Text string
+}
+
+// ChatRoom is a chat room on the server.
+type ChatRoom struct {
+	// Channel is a channel name of the room, including the '#' prefix.
+	Channel string
+	// Users is the number of users in the room.
+	Users int
+}
+
+type channel struct {
+	name  string
+	game  bool
+	typ   string // game type (ID)
+	host  string
+	max   int
+	ip    uint32
+	topic string
+	info  *xwis.GameInfo
+	extra int // users that are not connected to the server
+	users map[*conn]struct{}
+}
+
+func (ch *channel) nicks() []string {
+	var nicks []string
+	for u := range ch.users {
+		nick := u.nick
+		if ch.game && nick == ch.host {
+			nick = "@" + nick
+		}
+		nicks = append(nicks, nick)
+	}
+	sort.Strings(nicks)
+	return nicks
+}
+
+// setTopic sets the topic and decodes game info from it, if possible.
+func (ch *channel) setTopic(topic string) {
+	ch.topic = topic
+	ch.info = nil
+	if ch.game {
+		if info, err := xwis.DecodeTopic([]byte(topic)); err == nil {
+			ch.info = info
+		}
+	}
+}
+
+func (ch *channel) numUsers() int {
+	return ch.extra + len(ch.users)
+}
+
+// Server is a fake XWIS lobby server. It must be closed after use.
+type Server struct {
+	l    net.Listener
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	mu       sync.Mutex
+	conns    map[*conn]struct{}
+	channels map[string]*channel
+	lobbies  []xwis.LobbyServer
+	messages []Message
+	pongs    []string
+	noPong   bool
+	accounts map[string]string // apgar-encoded passwords
+	banned   map[string]bool
+	fail     map[string]string
+	ignore   map[string]bool
+	delay    map[string]time.Duration
+}
+
+// NewServer starts a new fake lobby server on a random local port.
+// It panics if the server cannot be started.
+func NewServer() *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Errorf("xwistest: failed to listen: %w", err))
+	}
+	s := &Server{
+		l:        l,
+		stop:     make(chan struct{}),
+		conns:    make(map[*conn]struct{}),
+		channels: make(map[string]*channel),
+		accounts: make(map[string]string),
+		banned:   make(map[string]bool),
+		fail:     make(map[string]string),
+		ignore:   make(map[string]bool),
+		delay:    make(map[string]time.Duration),
+	}
+	s.lobbies = []xwis.LobbyServer{
+		{Addr: s.Addr(), Name: "XWIS"},
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Addr returns the address of the server that can be passed to xwis.NewClientWithAddress.
+func (s *Server) Addr() string {
+	return s.l.Addr().String()
+}
+
+// Close stops the server and disconnects all clients.
+func (s *Server) Close() error {
+	select {
+	case <-s.stop:
+		return nil
+	default:
+	}
+	close(s.stop)
+	err := s.l.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		_ = c.c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// SetLobbyServers sets the list of lobby servers returned by the whereto command.
+// By default, the list contains only this server.
+func (s *Server) SetLobbyServers(list []xwis.LobbyServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lobbies = append([]xwis.LobbyServer{}, list...)
+}
+
+// AddChatRoom adds a chat room with a given number of (fake) users.
+// The name must include the '#' prefix.
+func (s *Server) AddChatRoom(name string, users int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := s.channels[name]
+	if ch == nil {
+		ch = &channel{name: name, users: make(map[*conn]struct{})}
+		s.channels[name] = ch
+	}
+	ch.extra = users
+}
+
+// Messages returns all chat messages sent by users, in the order they were received.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message{}, s.messages...)
+}
+
+// SendMessage sends a chat message from a given (possibly fake) user to a channel or a user.
+func (s *Server) SendMessage(from, to, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliverUnsafe(nil, &irc.Message{
+		Prefix:  &irc.Prefix{Name: from, User: "u", Host: "h"},
+		Command: "PRIVMSG",
+		Params:  []string{to, text},
+	})
+}
+
+// deliverUnsafe sends a message to all users in the channel or to the user with a given nick,
+// as specified by the first message parameter. The sender is excluded.
+func (s *Server) deliverUnsafe(from *conn, m *irc.Message) {
+	to := m.Params[0]
+	if ch := s.channels[to]; ch != nil {
+		for u := range ch.users {
+			if u != from {
+				u.send(m)
+			}
+		}
+		return
+	}
+	for u := range s.conns {
+		if u.loggedIn && u.nick == to {
+			u.send(m)
+		}
+	}
+}
+
+// Disconnect drops connections of the user with a given nick. Games hosted by the user are removed.
+// If nick is empty, all connections are dropped.
+func (s *Server) Disconnect(nick string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		if nick == "" || c.nick == nick {
+			_ = c.c.Close()
+			s.removeConnUnsafe(c)
+		}
+	}
+}
+
+// Ping sends PING with a given token to all logged in users.
+func (s *Server) Ping(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		if c.loggedIn {
+			c.send(&irc.Message{Command: "PING", Params: []string{token}})
+		}
+	}
+}
+
+// Pongs returns tokens of all PONG messages received from users.
+func (s *Server) Pongs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.pongs...)
+}
+
+// IgnorePing makes the server stop replying to PING, simulating a dead connection.
+func (s *Server) IgnorePing(ignore bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noPong = ignore
+}
+
+// SetPassword registers an account with a given login and a plain text password.
+// By default, any login is accepted with any password.
+func (s *Server) SetPassword(login, pass string) {
+	s.SetApgarPassword(login, xwis.Apgar(pass))
+}
+
+// SetApgarPassword is similar to SetPassword, but accepts the password encoded with the apgar algorithm,
+// exactly as it is expected from the client.
+func (s *Server) SetApgarPassword(login, apgar string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[login] = apgar
+}
+
+// Ban prevents the user with a given login from logging in.
+func (s *Server) Ban(login string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.banned[login] = true
+}
+
+// FailCommand makes the server reply with a given numeric error code to the next command cmd (e.g. "LIST").
+func (s *Server) FailCommand(cmd, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail[strings.ToUpper(cmd)] = code
+}
+
+// IgnoreCommand makes the server silently ignore the next command cmd (e.g. "LIST").
+func (s *Server) IgnoreCommand(cmd string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ignore[strings.ToUpper(cmd)] = true
+}
+
+// DelayCommand makes the server wait for a given duration before handling the next command cmd (e.g. "JOINGAME").
+// Commands that follow it on the same connection are delayed as well.
+func (s *Server) DelayCommand(cmd string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay[strings.ToUpper(cmd)] = d
+}
+
+// SetTopic sets a raw topic of the game channel, bypassing the host. It can be used to test malformed payloads.
+func (s *Server) SetTopic(channel, topic string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := s.channels[channel]
+	if ch == nil || !ch.game {
+		return
+	}
+	ch.setTopic(topic)
+}
+
+// Users returns nicks of all users logged in to the server.
+func (s *Server) Users() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for c := range s.conns {
+		if c.loggedIn {
+			out = append(out, c.nick)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Games returns all games registered on the server, sorted by the channel name.
+func (s *Server) Games() []Game {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Game
+	for _, ch := range s.channels {
+		if !ch.game {
+			continue
+		}
+		g := Game{
+			Channel:    ch.name,
+			Host:       ch.host,
+			Users:      ch.numUsers(),
+			MaxPlayers: ch.max,
+			Topic:      ch.topic,
+		}
+		if ch.info != nil {
+			info := *ch.info
+			g.Info = &info
+		}
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Channel < out[j].Channel
+	})
+	return out
+}
+
+// ChatRooms returns all chat rooms on the server, sorted by the channel name.
+func (s *Server) ChatRooms() []ChatRoom {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []ChatRoom
+	for _, ch := range s.channels {
+		if ch.game {
+			continue
+		}
+		out = append(out, ChatRoom{Channel: ch.name, Users: ch.numUsers()})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Channel < out[j].Channel
+	})
+	return out
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{s: s, c: nc, w: bufio.NewWriter(nc)}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			c.serve()
+		}()
+	}
+}
+
+func (s *Server) removeConn(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeConnUnsafe(c)
+}
+
+func (s *Server) removeConnUnsafe(c *conn) {
+	delete(s.conns, c)
+	for _, ch := range s.channels {
+		s.partUnsafe(c, ch)
+	}
+}
+
+func (s *Server) partUnsafe(c *conn, ch *channel) {
+	if _, ok := ch.users[c]; !ok {
+		return
+	}
+	delete(ch.users, c)
+	if ch.game && ch.host == c.nick {
+		delete(s.channels, ch.name)
+	}
+}
+
+type conn struct {
+	s *Server
+	c net.Conn
+
+	wmu sync.Mutex
+	w   *bufio.Writer
+
+	// written only by the serve goroutine while holding Server.mu
+	nick     string
+	pass     string
+	loggedIn bool
+}
+
+func (c *conn) ip() uint32 {
+	addr, ok := c.c.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return 0
+	}
+	ip := addr.IP.To4()
+	if ip == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(ip)
+}
+
+func (c *conn) send(m *irc.Message) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, _ = c.w.WriteString(m.String() + "\r\n")
+	_ = c.w.Flush()
+}
+
+func (c *conn) prefix() *irc.Prefix {
+	return &irc.Prefix{Name: c.nick, User: "u", Host: "h"}
+}
+
+func (c *conn) reply(code string, params ...string) {
+	nick := c.nick
+	if nick == "" {
+		nick = "*"
+	}
+	c.send(&irc.Message{
+		Prefix:  &irc.Prefix{Name: ServerName},
+		Command: code,
+		Params:  append([]string{nick}, params...),
+	})
+}
+
+func (c *conn) serve() {
+	defer c.s.removeConn(c)
+	defer c.c.Close()
+	sc := bufio.NewScanner(c.c)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+		m, err := irc.ParseMessage(line)
+		if err != nil {
+			continue
+		}
+		if !c.handle(m) {
+			return
+		}
+	}
+}
+
+func (c *conn) handle(m *irc.Message) bool {
+	c.s.mu.Lock()
+	code, fail := c.s.fail[m.Command]
+	delete(c.s.fail, m.Command)
+	ignore := c.s.ignore[m.Command]
+	delete(c.s.ignore, m.Command)
+	delay := c.s.delay[m.Command]
+	delete(c.s.delay, m.Command)
+	c.s.mu.Unlock()
+	if ignore {
+		return true
+	}
+	if delay > 0 {
+		select {
+		case <-c.s.stop:
+			return false
+		case <-time.After(delay):
+		}
+	}
+	if fail {
+		params := []string{"Command failed"}
+		if len(m.Params) > 0 && strings.HasPrefix(m.Params[0], "#") {
+			params = []string{m.Params[0], "Command failed"}
+		}
+		c.reply(code, params...)
+		return true
+	}
+	switch m.Command {
+	case "QUIT":
+		return false
+	case "PING":
+		c.s.mu.Lock()
+		ignore := c.s.noPong
+		c.s.mu.Unlock()
+		if !ignore {
+			c.send(&irc.Message{
+				Prefix:  &irc.Prefix{Name: ServerName},
+				Command: "PONG",
+				Params:  append([]string{ServerName}, m.Params...),
+			})
+		}
+	case "PONG":
+		c.s.mu.Lock()
+		c.s.pongs = append(c.s.pongs, m.Trailing())
+		c.s.mu.Unlock()
+	case "NICK":
+		if len(m.Params) > 0 {
+			c.s.mu.Lock()
+			c.nick = m.Params[0]
+			c.s.mu.Unlock()
+		}
+	case "APGAR":
+		if len(m.Params) > 0 {
+			c.s.mu.Lock()
+			c.pass = m.Params[0]
+			c.s.mu.Unlock()
+		}
+	case "USER":
+		if code, text := c.login(); code != "" {
+			c.reply(code, text)
+			return false
+		}
+		c.reply("375", "- "+ServerName+" Message of the Day -")
+		c.reply("372", "- Welcome to the fake XWIS server")
+		c.reply("376", "End of /MOTD command")
+	case "VERCHK":
+		c.reply("379", "none")
+	case "SETCODEPAGE":
+		if len(m.Params) > 0 {
+			c.reply("329", m.Params[0])
+		}
+	case "LOBCOUNT":
+		c.reply("610", "1")
+	case "WHERETO":
+		c.s.mu.Lock()
+		lobbies := append([]xwis.LobbyServer{}, c.s.lobbies...)
+		c.s.mu.Unlock()
+		for _, l := range lobbies {
+			host, port, err := net.SplitHostPort(l.Addr)
+			if err != nil {
+				continue
+			}
+			c.reply("605", fmt.Sprintf("%s %s '0:%s' -8 36.1083 -115.0582", host, port, l.Name))
+		}
+		c.reply("607")
+	case "LIST":
+		c.handleList(m)
+	case "JOINGAME":
+		c.handleJoinGame(m)
+	case "TOPIC":
+		c.handleTopic(m)
+	case "JOIN":
+		c.handleJoin(m)
+	case "PRIVMSG":
+		if len(m.Params) < 2 {
+			break
+		}
+		c.s.mu.Lock()
+		c.s.messages = append(c.s.messages, Message{From: c.nick, To: m.Params[0], Text: m.Params[1]})
+		c.s.deliverUnsafe(c, &irc.Message{
+			Prefix:  c.prefix(),
+			Command: "PRIVMSG",
+			Params:  []string{m.Params[0], m.Params[1]},
+		})
+		c.s.mu.Unlock()
+	case "PART":
+		if len(m.Params) > 0 {
+			c.s.mu.Lock()
+			if ch := c.s.channels[m.Params[0]]; ch != nil {
+				c.s.partUnsafe(c, ch)
+			}
+			c.s.mu.Unlock()
+		}
+	}
+	return true
+}
+
+// login checks the credentials of the user. It returns a numeric error code and text if the login fails.
+func (c *conn) login() (string, string) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	if c.nick == "" {
+		return "431", "No nickname given"
+	}
+	if c.s.banned[c.nick] {
+		return "465", "You are banned from this server"
+	}
+	if pass, ok := c.s.accounts[c.nick]; ok && pass != c.pass {
+		return "464", "Password incorrect"
+	}
+	for u := range c.s.conns {
+		if u != c && u.loggedIn && u.nick == c.nick {
+			return "433", "Nickname is already in use"
+		}
+	}
+	c.loggedIn = true
+	return "", ""
+}
+
+func (c *conn) handleList(m *irc.Message) {
+	typ := ""
+	if len(m.Params) > 1 {
+		typ = m.Params[1]
+	}
+	c.s.mu.Lock()
+	var lines [][]string
+	for _, ch := range c.s.channels {
+		users := strconv.Itoa(ch.numUsers())
+		if ch.game {
+			if typ != "" && ch.typ != typ {
+				continue
+			}
+			lines = append(lines, []string{
+				"326", ch.name, users, strconv.Itoa(ch.max), ch.typ, "0", "0",
+				strconv.FormatUint(uint64(ch.ip), 10), payloadPrefix + "::" + ch.topic,
+			})
+		} else {
+			lines = append(lines, []string{"327", ch.name, users, "0", "388"})
+		}
+	}
+	c.s.mu.Unlock()
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i][1] < lines[j][1]
+	})
+	for _, l := range lines {
+		c.reply(l[0], l[1:]...)
+	}
+	c.reply("323", "End of /LIST")
+}
+
+func (c *conn) handleJoin(m *irc.Message) {
+	if len(m.Params) < 1 {
+		return
+	}
+	name := m.Params[0]
+	c.s.mu.Lock()
+	ch := c.s.channels[name]
+	if ch == nil {
+		ch = &channel{name: name, users: make(map[*conn]struct{})}
+		c.s.channels[name] = ch
+	}
+	ch.users[c] = struct{}{}
+	nicks := ch.nicks()
+	c.s.deliverUnsafe(nil, &irc.Message{
+		Prefix:  c.prefix(),
+		Command: "JOIN",
+		Params:  []string{"0,0", name},
+	})
+	c.s.mu.Unlock()
+
+	c.reply("353", "=", name, strings.Join(nicks, " "))
+	c.reply("366", name, "End of /NAMES list.")
+}
+
+func (c *conn) handleJoinGame(m *irc.Message) {
+	if len(m.Params) < 3 {
+		return
+	}
+	name := m.Params[0]
+	max, _ := strconv.Atoi(m.Params[2])
+	typ := ""
+	if len(m.Params) > 3 {
+		typ = m.Params[3]
+	}
+	c.s.mu.Lock()
+	ch := c.s.channels[name]
+	if ch != nil && ch.game && ch.host != c.nick {
+		c.s.mu.Unlock()
+		c.reply("437", name, "Channel already exists")
+		return
+	}
+	if ch == nil {
+		ch = &channel{
+			name:  name,
+			game:  true,
+			typ:   typ,
+			host:  c.nick,
+			max:   max,
+			ip:    c.ip(),
+			users: make(map[*conn]struct{}),
+		}
+		c.s.channels[name] = ch
+	}
+	ch.users[c] = struct{}{}
+	nicks := ch.nicks()
+	c.s.mu.Unlock()
+
+	c.send(&irc.Message{
+		Prefix:  c.prefix(),
+		Command: "JOINGAME",
+		Params:  append(m.Params[1:len(m.Params):len(m.Params)], name),
+	})
+	c.reply("353", "=", name, strings.Join(nicks, " "))
+	c.reply("366", name, "End of /NAMES list.")
+}
+
+func (c *conn) handleTopic(m *irc.Message) {
+	if len(m.Params) < 2 {
+		return
+	}
+	name, topic := m.Params[0], m.Params[1]
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	ch := c.s.channels[name]
+	if ch == nil {
+		return
+	}
+	if _, ok := ch.users[c]; !ok {
+		return
+	}
+	ch.setTopic(topic)
+}